@@ -0,0 +1,163 @@
+package ext
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+const defaultBulkConcurrency = 4
+
+type BulkOptions struct {
+	// Concurrency is the number of operations in flight, defaults to 4.
+	Concurrency int
+	// RateLimit caps the number of operations started per second, zero
+	// means unlimited as do rates above one per nanosecond.
+	RateLimit float64
+}
+
+type BulkResult struct {
+	Hostname  string
+	Container *pfmodel.Container
+	Err       error
+}
+
+// BulkReport holds one result per requested container, in request order.
+type BulkReport []BulkResult
+
+func (r BulkReport) Succeeded() BulkReport {
+	var res BulkReport
+	for _, result := range r {
+		if result.Err == nil {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+func (r BulkReport) Failed() BulkReport {
+	var res BulkReport
+	for _, result := range r {
+		if result.Err != nil {
+			res = append(res, result)
+		}
+	}
+	return res
+}
+
+// Err summarizes the failed operations, or returns nil when all succeeded.
+func (r BulkReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(failed))
+	for i, result := range failed {
+		msgs[i] = fmt.Sprintf("%s: %s", result.Hostname, result.Err.Error())
+	}
+	return fmt.Errorf("%d of %d operations failed: %s", len(failed), len(r), strings.Join(msgs, "; "))
+}
+
+func BulkDelete(ctx context.Context, c Client, hostnames []string, opts BulkOptions) BulkReport {
+	c = c.WithContext(ctx)
+	return runBulk(ctx, hostnames, opts, func(i int) (*pfmodel.Container, error) {
+		return c.DeleteContainer(hostnames[i])
+	})
+}
+
+func BulkReschedule(ctx context.Context, c Client, hostnames []string, opts BulkOptions) BulkReport {
+	c = c.WithContext(ctx)
+	return runBulk(ctx, hostnames, opts, func(i int) (*pfmodel.Container, error) {
+		return c.RescheduleContainer(hostnames[i])
+	})
+}
+
+func BulkRelocate(ctx context.Context, c Client, hostnames []string, relocateOpts RelocateOptions, opts BulkOptions) BulkReport {
+	c = c.WithContext(ctx)
+	return runBulk(ctx, hostnames, opts, func(i int) (*pfmodel.Container, error) {
+		return c.RelocateContainer(hostnames[i], relocateOpts)
+	})
+}
+
+func BulkCreate(ctx context.Context, c Client, containers []pfmodel.Container, opts BulkOptions) BulkReport {
+	hostnames := make([]string, len(containers))
+	for i, cntr := range containers {
+		hostnames[i] = cntr.Hostname
+	}
+
+	c = c.WithContext(ctx)
+	return runBulk(ctx, hostnames, opts, func(i int) (*pfmodel.Container, error) {
+		return c.CreateContainer(containers[i])
+	})
+}
+
+// runBulk calls op for every index of hostnames using a bounded worker pool.
+// Operations that never started because ctx was done report ctx.Err().
+func runBulk(ctx context.Context, hostnames []string, opts BulkOptions, op func(i int) (*pfmodel.Container, error)) BulkReport {
+	report := make(BulkReport, len(hostnames))
+	for i, hostname := range hostnames {
+		report[i].Hostname = hostname
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	var tick <-chan time.Time
+	if opts.RateLimit > 0 {
+		// Rates too high for their interval to be represented are unlimited
+		if interval := time.Duration(float64(time.Second) / opts.RateLimit); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				report[i].Container, report[i].Err = op(i)
+			}
+		}()
+	}
+
+	next := 0
+dispatch:
+	for ; next < len(hostnames); next++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if tick != nil && next > 0 {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-tick:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- next:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for ; next < len(hostnames); next++ {
+		report[next].Err = ctx.Err()
+	}
+
+	return report
+}
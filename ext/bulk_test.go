@@ -0,0 +1,129 @@
+package ext
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBulkDelete(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		hostname := strings.Split(req.URL.Path, "/")[2]
+		if hostname == "test-03" {
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte("container not found"))
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "` + hostname + `", "status": "SCHEDULE_DELETION"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	hostnames := []string{"test-01", "test-02", "test-03", "test-04", "test-05", "test-06"}
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"DeleteContainer": "containers"})
+	report := BulkDelete(context.Background(), client, hostnames, BulkOptions{Concurrency: 2})
+
+	for i, result := range report {
+		if result.Hostname != hostnames[i] {
+			t.Errorf("Incorrect result order, got: %s, want: %s.", result.Hostname, hostnames[i])
+		}
+	}
+
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Hostname != "test-03" {
+		t.Errorf("Incorrect failed results, got: %+v", failed)
+	}
+
+	if len(report.Succeeded()) != 5 {
+		t.Errorf("Incorrect number of succeeded results, got: %d, want: %d.", len(report.Succeeded()), 5)
+	}
+
+	if report.Err() == nil {
+		t.Errorf("Report with failures should return an error")
+	}
+
+	if maxInFlight > 2 {
+		t.Errorf("Concurrency limit exceeded, got: %d, want at most: %d.", maxInFlight, 2)
+	}
+}
+
+func TestBulkRescheduleRateLimit(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"status": "PENDING"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	start := time.Now()
+	report := BulkReschedule(context.Background(), client, []string{"test-01", "test-02", "test-03"}, BulkOptions{RateLimit: 50})
+
+	if err := report.Err(); err != nil {
+		t.Errorf("Error when rescheduling containers: %s", err.Error())
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Rate limit not applied, took: %s", elapsed)
+	}
+}
+
+func TestBulkCancelled(t *testing.T) {
+	called := false
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		called = true
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	report := BulkRelocate(ctx, client, []string{"test-01", "test-02"}, RelocateOptions{}, BulkOptions{})
+
+	if called {
+		t.Errorf("No request should be sent once the context is cancelled")
+	}
+
+	for _, result := range report {
+		if result.Err != context.Canceled {
+			t.Errorf("Incorrect error for %s, got: %v, want: %v.", result.Hostname, result.Err, context.Canceled)
+		}
+	}
+}
+
+func TestBulkDeleteUnboundedRateLimit(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "status": "SCHEDULE_DELETION"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"DeleteContainer": "containers"})
+	for _, rate := range []float64{2e9, math.Inf(1)} {
+		report := BulkDelete(context.Background(), client, []string{"test-01", "test-02"}, BulkOptions{RateLimit: rate})
+		if err := report.Err(); err != nil {
+			t.Errorf("Rate %v should be treated as unlimited, got: %s", rate, err.Error())
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package ext
 
 import (
-	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/transport"
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/version"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
 	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,10 +22,36 @@ type Client interface {
 	GetNode(string) (*pfmodel.Node, error)
 	GetContainers() (*pfmodel.ContainerList, error)
 	GetContainer(string) (*pfmodel.Container, error)
+	GetContainerEvents(string) (*pfmodel.ContainerEventList, error)
 	CreateContainer(pfmodel.Container) (*pfmodel.Container, error)
 	DeleteContainer(string) (*pfmodel.Container, error)
 	RescheduleContainer(string) (*pfmodel.Container, error)
-	RelocateContainer(hostname, nodeHostname, clusterName string) (*pfmodel.Container, error)
+	RelocateContainer(hostname string, opts RelocateOptions) (*pfmodel.Container, error)
+	CordonNode(string) (*pfmodel.Node, error)
+	UncordonNode(string) (*pfmodel.Node, error)
+	DeleteNode(string) (*pfmodel.Node, error)
+	EvacuateNode(string) (*pfmodel.Node, error)
+	ListClusters() (*pfmodel.ClusterList, error)
+	GetCluster(string) (*pfmodel.Cluster, error)
+	CreateCluster(CreateClusterRequest) (*pfmodel.Cluster, error)
+	WithCluster(string) Client
+	WithContext(context.Context) Client
+	ServerAPIVersion() string
+}
+
+// APIError is returned when Pathfinder server answers with an unexpected
+// status.
+type APIError = transport.Error
+
+// ErrCircuitOpen is returned without contacting the server while the
+// breaker given with WithBreaker is open.
+var ErrCircuitOpen = pfbreaker.ErrCircuitOpen
+
+// ContextWithIdempotencyKey pins the idempotency key of the writes sent with
+// the returned context, given to WithContext, so a CreateContainer retried
+// with the same key and arguments is applied once by the server.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return transport.ContextWithIdempotencyKey(ctx, key)
 }
 
 type client struct {
@@ -30,6 +60,10 @@ type client struct {
 	httpClient   *http.Client
 	pfServerAddr string
 	pfApiPath    map[string]string
+	tr           *transport.Transport
+	versions     *version.Tracker
+	tokenSource  *pfsecret.Source
+	ctx          context.Context
 }
 
 func NewClient(
@@ -37,168 +71,245 @@ func NewClient(
 	token string,
 	httpClient *http.Client,
 	pfServerAddr string,
-	pfApiPath map[string]string) Client {
+	pfApiPath map[string]string,
+	opts ...Option) Client {
 
-	return &client{
+	c := &client{
 		cluster:      cluster,
 		token:        token,
 		httpClient:   httpClient,
 		pfServerAddr: pfServerAddr,
 		pfApiPath:    pfApiPath,
+		tr:           transport.New(httpClient, pfServerAddr),
+		versions:     version.New(false),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-func (c *client) GetNodes() (*pfmodel.NodeList, error) {
-	addr := fmt.Sprintf("%s/%s", c.pfServerAddr, c.pfApiPath["GetNodes"])
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
+// WithCluster returns a client for another cluster that shares this client's
+// HTTP client, token and API paths.
+func (c *client) WithCluster(cluster string) Client {
+	derived := *c
+	derived.cluster = cluster
+	return &derived
+}
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
+// WithContext returns a client whose requests are sent with ctx, so they
+// can be cancelled while waiting on the server or on a limiter given with
+// WithLimiter.
+func (c *client) WithContext(ctx context.Context) Client {
+	return c.withContext(ctx)
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+func (c *client) withContext(ctx context.Context) *client {
+	derived := *c
+	derived.ctx = ctx
+	return &derived
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	nodes, err := NewNodeListFromByte(b)
+func (c *client) ListClusters() (*pfmodel.ClusterList, error) {
+	var clusters *pfmodel.ClusterList
+	err := c.do(c.requestContext(), &transport.Request{
+		Operation: "ListClusters",
+		Method:    http.MethodGet,
+		Path:      c.pfApiPath["ListClusters"],
+	}, transport.ReadAll(func(b []byte) error {
+		dec, err := c.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		clusters, err = dec.clusterList(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
-	return nodes, nil
+	return clusters, nil
 }
 
-func (c *client) GetNode(nodeHostname string) (*pfmodel.Node, error) {
-	addr := fmt.Sprintf("%s/%s/%s", c.pfServerAddr, c.pfApiPath["GetNode"], nodeHostname)
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
+func (c *client) GetCluster(name string) (*pfmodel.Cluster, error) {
+	return clusterRequest(c, &transport.Request{
+		Operation: "GetCluster",
+		Method:    http.MethodGet,
+		Path:      fmt.Sprintf("%s/%s", c.pfApiPath["GetCluster"], name),
+	})
+}
+
+func (c *client) CreateCluster(cluster CreateClusterRequest) (*pfmodel.Cluster, error) {
+	form := url.Values{}
+	form.Set("cluster[name]", cluster.Name)
+	form.Set("cluster[password]", cluster.Password)
+
+	return clusterRequest(c, &transport.Request{
+		Operation:   "CreateCluster",
+		Method:      http.MethodPost,
+		Path:        c.pfApiPath["CreateCluster"],
+		Body:        []byte(form.Encode()),
+		ContentType: transport.FormContentType,
+	})
+}
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
+func (c *client) GetNodes() (*pfmodel.NodeList, error) {
+	v, err := c.doCached(c.requestContext(), c.request("GetNodes", http.MethodGet, c.pfApiPath["GetNodes"]),
+		transport.ReadAllValue(func(b []byte) (interface{}, error) {
+			dec, err := c.decodersFor(b)
+			if err != nil {
+				return nil, err
+			}
+			nodes, err := dec.nodeList(b)
+			if err != nil {
+				return nil, err
+			}
+			return *nodes, nil
+		}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+	// The list may be shared with the cache, hand out a copy
+	nodes := v.(pfmodel.NodeList).Copy()
+	return &nodes, nil
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	node, err := NewNodeFromByte(b)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
+func (c *client) GetNode(nodeHostname string) (*pfmodel.Node, error) {
+	path := fmt.Sprintf("%s/%s", c.pfApiPath["GetNode"], nodeHostname)
+	return nodeRequest(c, c.request("GetNode", http.MethodGet, path))
+}
 
-	return node, nil
+func (c *client) CordonNode(nodeHostname string) (*pfmodel.Node, error) {
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["CordonNode"], nodeHostname, "cordon")
+	return nodeRequest(c, c.request("CordonNode", http.MethodPost, path))
 }
 
-func (c *client) GetContainers() (*pfmodel.ContainerList, error) {
-	addr := fmt.Sprintf("%s/%s", c.pfServerAddr, c.pfApiPath["GetContainers"])
-	u, err := url.Parse(addr)
+func (c *client) UncordonNode(nodeHostname string) (*pfmodel.Node, error) {
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["UncordonNode"], nodeHostname, "uncordon")
+	return nodeRequest(c, c.request("UncordonNode", http.MethodPost, path))
+}
+
+func (c *client) DeleteNode(nodeHostname string) (*pfmodel.Node, error) {
+	path := fmt.Sprintf("%s/%s", c.pfApiPath["DeleteNode"], nodeHostname)
+	return nodeRequest(c, c.request("DeleteNode", http.MethodDelete, path))
+}
+
+// EvacuateNode cordons the node and relocates each of its containers to the
+// remaining schedulable nodes, spreading them starting from the node with
+// the most free memory. With a tracer, its requests share one span.
+func (c *client) EvacuateNode(nodeHostname string) (node *pfmodel.Node, err error) {
+	ctx, end := c.transport().StartOperation(c.requestContext(), "EvacuateNode")
+	defer func() { end(err) }()
+	c = c.withContext(ctx)
+
+	node, err = c.CordonNode(nodeHostname)
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
+	containers, err := c.GetContainers()
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+		return node, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
+	var evacuees pfmodel.ContainerList
+	for _, cntr := range *containers {
+		if cntr.NodeHostname != nodeHostname ||
+			cntr.Status == pfmodel.StatusScheduleDeletion ||
+			cntr.Status == pfmodel.StatusDeleted {
+			continue
+		}
+		evacuees = append(evacuees, cntr)
+	}
+	if len(evacuees) == 0 {
+		return node, nil
 	}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	containers, err := NewContainerListFromByte(b)
+	nodes, err := c.GetNodes()
 	if err != nil {
+		return node, err
+	}
+
+	var targets pfmodel.NodeList
+	for _, n := range nodes.Schedulable() {
+		if n.Hostname != nodeHostname {
+			targets = append(targets, n)
+		}
+	}
+	if len(targets) == 0 {
+		err := fmt.Errorf("no schedulable node to evacuate %s to", nodeHostname)
 		log.Error(err.Error())
-		return nil, err
+		return node, err
+	}
+	targets.SortByFreeMemory()
+
+	var failed []string
+	for i, cntr := range evacuees {
+		target := targets[i%len(targets)]
+		_, err := c.RelocateContainer(cntr.Hostname, RelocateOptions{
+			NodeHostname: target.Hostname,
+			Reason:       fmt.Sprintf("evacuating %s", nodeHostname),
+		})
+		if err != nil {
+			failed = append(failed, cntr.Hostname)
+		}
+	}
+	if len(failed) > 0 {
+		err := fmt.Errorf("failed to relocate %s from %s", strings.Join(failed, ", "), nodeHostname)
+		log.Error(err.Error())
+		return node, err
 	}
 
-	return containers, nil
+	return node, nil
 }
 
-func (c *client) GetContainer(containerHostname string) (*pfmodel.Container, error) {
-	addr := fmt.Sprintf("%s/%s/%s", c.pfServerAddr, c.pfApiPath["GetContainer"], containerHostname)
-	u, err := url.Parse(addr)
+func (c *client) GetContainers() (*pfmodel.ContainerList, error) {
+	v, err := c.doCached(c.requestContext(), c.request("GetContainers", http.MethodGet, c.pfApiPath["GetContainers"]),
+		func(r io.Reader) (interface{}, error) {
+			containers, err := c.readContainerList(r)
+			if err != nil {
+				return nil, err
+			}
+			return *containers, nil
+		})
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
+	// The list may be shared with the cache, hand out a copy
+	containers := v.(pfmodel.ContainerList).Copy()
+	return &containers, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+func (c *client) GetContainer(containerHostname string) (*pfmodel.Container, error) {
+	path := fmt.Sprintf("%s/%s", c.pfApiPath["GetContainer"], containerHostname)
+	return containerRequest(c, c.request("GetContainer", http.MethodGet, path))
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	container, err := NewContainerFromByte(b)
+// GetContainerEvents returns the status transitions of a container, oldest
+// first.
+func (c *client) GetContainerEvents(hostname string) (*pfmodel.ContainerEventList, error) {
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["GetContainerEvents"], hostname, "events")
+
+	var events *pfmodel.ContainerEventList
+	err := c.do(c.requestContext(), c.request("GetContainerEvents", http.MethodGet, path), transport.ReadAll(func(b []byte) error {
+		dec, err := c.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		events, err = dec.eventList(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
-	return container, nil
+	return events, nil
 }
 
 func (c *client) CreateContainer(cntr pfmodel.Container) (*pfmodel.Container, error) {
-	addr := fmt.Sprintf("%s/%s", c.pfServerAddr, c.pfApiPath["CreateContainer"])
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
-
 	form := url.Values{}
 	form.Set("container[hostname]", cntr.Hostname)
 	form.Set("container[source][source_type]", cntr.Source.Type)
@@ -207,126 +318,173 @@ func (c *client) CreateContainer(cntr pfmodel.Container) (*pfmodel.Container, er
 	form.Set("container[source][remote][server]", cntr.Source.Remote.Server)
 	form.Set("container[source][remote][protocol]", cntr.Source.Remote.Protocol)
 	form.Set("container[source][remote][certificate]", cntr.Source.Remote.Certificate)
-	body := bytes.NewBufferString(form.Encode())
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), body)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
-	}
+	r := c.request("CreateContainer", http.MethodPost, c.pfApiPath["CreateContainer"])
+	r.Body = []byte(form.Encode())
+	r.ContentType = transport.FormContentType
+	return containerRequest(c, r)
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+func (c *client) DeleteContainer(hostname string) (*pfmodel.Container, error) {
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["DeleteContainer"], hostname, "schedule_deletion")
+	return containerRequest(c, c.request("DeleteContainer", http.MethodPost, path))
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	cntrRes, err := NewContainerFromByte(b)
+func (c *client) RescheduleContainer(hostname string) (*pfmodel.Container, error) {
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["RescheduleContainer"], hostname, "reschedule")
+	return containerRequest(c, c.request("RescheduleContainer", http.MethodPost, path))
+}
+
+func (c *client) RelocateContainer(hostname string, opts RelocateOptions) (*pfmodel.Container, error) {
+	b, err := json.Marshal(newRelocateReq(c.cluster, opts))
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
 	}
 
-	return cntrRes, nil
+	path := fmt.Sprintf("%s/%s/%s", c.pfApiPath["RelocateContainer"], hostname, "schedule_relocation")
+	r := c.request("RelocateContainer", http.MethodPost, path)
+	r.Body = b
+	r.ContentType = transport.JSONContentType
+	return containerRequest(c, r)
 }
 
-func (c *client) DeleteContainer(hostname string) (*pfmodel.Container, error) {
-	addr := fmt.Sprintf("%s/%s/%s/%s", c.pfServerAddr, c.pfApiPath["DeleteContainer"], hostname, "schedule_deletion")
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+// do performs r with the client's token. A token read from a provider is
+// read again when the server rejects it, and r retried once if it changed.
+func (c *client) do(ctx context.Context, r *transport.Request, decode func(io.Reader) error) error {
+	return c.withToken(ctx, r, func(ctx context.Context) error {
+		return c.transport().Do(ctx, r, decode)
+	})
+}
+
+func (c *client) doCached(ctx context.Context, r *transport.Request, decode func(io.Reader) (interface{}, error)) (interface{}, error) {
+	var v interface{}
+	err := c.withToken(ctx, r, func(ctx context.Context) (err error) {
+		v, err = c.transport().DoCached(ctx, r, decode)
+		return err
+	})
+	return v, err
+}
+
+// withToken sets the token of r before calling send. With a token provider,
+// the retry and the first attempt share one operation span.
+func (c *client) withToken(ctx context.Context, r *transport.Request, send func(context.Context) error) (err error) {
+	if c.tokenSource == nil {
+		r.Token = c.token
+		return send(ctx)
 	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
+	ctx, end := c.transport().StartOperation(ctx, r.Operation)
+	defer func() { end(err) }()
+
+	token, err := c.tokenSource.Get(ctx)
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return err
 	}
-
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
+	r.Token = token
+	err = send(ctx)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		return err
 	}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	container, err := NewContainerFromByte(b)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+	changed, refreshErr := c.tokenSource.Refresh(ctx)
+	if refreshErr != nil {
+		log.Error(refreshErr.Error())
+		return err
 	}
+	if !changed {
+		return err
+	}
+	r.Token, _ = c.tokenSource.Get(ctx)
+	return send(ctx)
+}
 
-	return container, nil
+// String keeps the token out of logs and %v output.
+func (c *client) String() string {
+	return fmt.Sprintf("ext.client{cluster: %s, server: %s, token: %s}",
+		c.cluster, c.pfServerAddr, pfsecret.Redacted)
 }
 
-func (c *client) RescheduleContainer(hostname string) (*pfmodel.Container, error) {
-	addr := fmt.Sprintf("%s/%s/%s/%s", c.pfServerAddr, c.pfApiPath["RescheduleContainer"], hostname, "reschedule")
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+func (c *client) GoString() string {
+	return c.String()
+}
+
+// requestContext returns the context given with WithContext, if any.
+func (c *client) requestContext() context.Context {
+	if c.ctx != nil {
+		return c.ctx
 	}
-	q := u.Query()
-	q.Set("cluster_name", c.cluster)
-	u.RawQuery = q.Encode()
+	return context.Background()
+}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
-	req.Header.Set("X-Auth-Token", c.token)
-	res, err := c.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+// transport returns the shared request plumbing, building a throwaway one
+// for clients that were not created through NewClient.
+func (c *client) transport() *transport.Transport {
+	if c.tr != nil {
+		return c.tr
 	}
+	return transport.New(c.httpClient, c.pfServerAddr)
+}
+
+// request prepares a request scoped to the client's cluster.
+func (c *client) request(operation, method, path string) *transport.Request {
+	q := url.Values{}
+	q.Set("cluster_name", c.cluster)
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
+	return &transport.Request{
+		Operation: operation,
+		Method:    method,
+		Path:      path,
+		Query:     q,
 	}
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	container, err := NewContainerFromByte(b)
+func clusterRequest(c *client, r *transport.Request) (*pfmodel.Cluster, error) {
+	var cluster *pfmodel.Cluster
+	err := c.do(c.requestContext(), r, transport.ReadAll(func(b []byte) error {
+		dec, err := c.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		cluster, err = dec.cluster(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
-	return container, nil
+	return cluster, nil
 }
 
-func (c *client) RelocateContainer(hostname, nodeHostname, clusterName string) (*pfmodel.Container, error) {
-	addr := fmt.Sprintf("%s/%s/%s/%s", c.pfServerAddr, c.pfApiPath["RelocateContainer"], hostname, "schedule_relocation")
-	bodyTemplate := `{"cluster_name": "%s", "node_hostname": "%s"}`
-	body := fmt.Sprintf(bodyTemplate, clusterName, nodeHostname)
-
-	req, err := http.NewRequest(http.MethodPost, addr, strings.NewReader(body))
-	req.Header.Set("X-Auth-Token", c.token)
-	req.Header.Set("Content-type", "application/json")
-	res, err := c.httpClient.Do(req)
+func nodeRequest(c *client, r *transport.Request) (*pfmodel.Node, error) {
+	var node *pfmodel.Node
+	err := c.do(c.requestContext(), r, transport.ReadAll(func(b []byte) error {
+		dec, err := c.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		node, err = dec.node(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+	return node, nil
+}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	container, err := NewContainerFromByte(b)
+func containerRequest(c *client, r *transport.Request) (*pfmodel.Container, error) {
+	var container *pfmodel.Container
+	err := c.do(c.requestContext(), r, transport.ReadAll(func(b []byte) error {
+		dec, err := c.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		container, err = dec.container(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return nil, err
 	}
 
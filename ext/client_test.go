@@ -1,28 +1,36 @@
 package ext
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pathfinder-cm/pathfinder-go-client/pfcache"
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
 	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftrace"
 )
 
 func TestGetNodes(t *testing.T) {
 	tables := []struct {
 		hostname   string
 		ipaddress  string
-		createdAt  string
+		createdAt  time.Time
 		memFreeMb  uint64
 		memUsedMb  uint64
 		memTotalMb uint64
 	}{
-		{"test-01", "192.168.1.100", "2018-01-01", 100, 100, 200},
-		{"test-02", "192.168.1.101", "2018-01-01", 100, 100, 200},
-		{"test-03", "192.168.1.102", "2018-01-01", 100, 100, 200},
+		{"test-01", "192.168.1.100", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 100, 100, 200},
+		{"test-02", "192.168.1.101", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 100, 100, 200},
+		{"test-03", "192.168.1.102", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 100, 100, 200},
 	}
 
 	b := []byte(`{
@@ -57,7 +65,7 @@ func TestGetNodes(t *testing.T) {
 				table.ipaddress)
 		}
 
-		if (*nodes)[i].CreatedAt != table.createdAt {
+		if !(*nodes)[i].CreatedAt.Equal(table.createdAt) {
 			t.Errorf("Incorrect node CreatedAt generated, got: %s, want: %s.",
 				(*nodes)[i].CreatedAt,
 				table.createdAt)
@@ -83,16 +91,46 @@ func TestGetNodes(t *testing.T) {
 	}
 }
 
+func TestGetNodesTimestamps(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{
+			"api_version": "1.0",
+			"data": {
+				"items": [
+					{"hostname": "test-01", "created_at": "2018-01-01 10:00:00 +0700"},
+					{"hostname": "test-02", "created_at": "the first of january"}
+				]
+			}
+		}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	nodes, err := client.GetNodes()
+	if err != nil {
+		t.Fatalf("Error when getting nodes: %s", err.Error())
+	}
+
+	createdAt := time.Date(2018, 1, 1, 3, 0, 0, 0, time.UTC)
+	if !(*nodes)[0].CreatedAt.Equal(createdAt) {
+		t.Errorf("Incorrect node CreatedAt generated, got: %s, want: %s.", (*nodes)[0].CreatedAt, createdAt)
+	}
+	if !(*nodes)[1].CreatedAt.IsZero() {
+		t.Errorf("Unparseable CreatedAt should be left zero, got: %s", (*nodes)[1].CreatedAt)
+	}
+}
+
 func TestGetNode(t *testing.T) {
 	tables := []struct {
 		hostname   string
 		ipaddress  string
-		createdAt  string
+		createdAt  time.Time
 		memFreeMb  uint64
 		memUsedMb  uint64
 		memTotalMb uint64
 	}{
-		{"test-01", "192.168.1.100", "2018-01-01", 100, 100, 200},
+		{"test-01", "192.168.1.100", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), 100, 100, 200},
 	}
 
 	b := []byte(`{
@@ -128,7 +166,7 @@ func TestGetNode(t *testing.T) {
 			tables[0].ipaddress)
 	}
 
-	if node.CreatedAt != tables[0].createdAt {
+	if !node.CreatedAt.Equal(tables[0].createdAt) {
 		t.Errorf("Incorrect node CreatedAt generated, got: %s, want: %s.",
 			node.CreatedAt,
 			tables[0].createdAt)
@@ -153,6 +191,74 @@ func TestGetNode(t *testing.T) {
 	}
 }
 
+func TestGetNodeWithMetrics(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {
+			"id": 1,
+			"hostname": "test-01",
+			"ipaddress": "192.168.1.100",
+			"created_at": "2018-01-01T10:00:00.000Z",
+			"mem_free_mb": 100,
+			"mem_used_mb": 100,
+			"mem_total_mb": 200,
+			"metrics": {
+				"memory": {"used": 100, "free": 100, "total": 200},
+				"load": {"capacity": 4, "load_avg_1m": 0.5, "load_avg_5m": 0.25, "load_avg_15m": 0.125},
+				"disk_root": {"total": 1000, "used": 400},
+				"disk_zfs": {"total": 5000, "used": 1000}
+			},
+			"last_heartbeat_at": "2018-01-02T10:00:00.000Z",
+			"metrics_updated_at": "2018-01-02T09:59:00.000Z",
+			"schedulable": false,
+			"cordoned": true
+		}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	node, err := client.GetNode("test-01")
+	if err != nil {
+		t.Fatalf("Error when getting node: %s", err.Error())
+	}
+
+	createdAt := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	if !node.CreatedAt.Equal(createdAt) {
+		t.Errorf("Incorrect node CreatedAt generated, got: %s, want: %s.", node.CreatedAt, createdAt)
+	}
+
+	lastHeartbeatAt := time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+	if !node.LastHeartbeatAt.Equal(lastHeartbeatAt) {
+		t.Errorf("Incorrect node LastHeartbeatAt generated, got: %s, want: %s.", node.LastHeartbeatAt, lastHeartbeatAt)
+	}
+
+	metricsUpdatedAt := time.Date(2018, 1, 2, 9, 59, 0, 0, time.UTC)
+	if !node.MetricsUpdatedAt.Equal(metricsUpdatedAt) {
+		t.Errorf("Incorrect node MetricsUpdatedAt generated, got: %s, want: %s.", node.MetricsUpdatedAt, metricsUpdatedAt)
+	}
+
+	if node.Metrics.Load == nil || node.Metrics.Load.LoadAvg5M != 0.25 {
+		t.Errorf("Incorrect node load metrics generated, got: %+v", node.Metrics.Load)
+	}
+
+	if node.Metrics.RootDisk == nil || node.Metrics.RootDisk.Used != 400 {
+		t.Errorf("Incorrect node root disk metrics generated, got: %+v", node.Metrics.RootDisk)
+	}
+
+	if node.Metrics.ZFSDisk == nil || node.Metrics.ZFSDisk.Total != 5000 {
+		t.Errorf("Incorrect node zfs disk metrics generated, got: %+v", node.Metrics.ZFSDisk)
+	}
+
+	if node.Schedulable || !node.Cordoned {
+		t.Errorf("Incorrect node schedulability generated, got: schedulable=%t cordoned=%t.", node.Schedulable, node.Cordoned)
+	}
+}
+
 func TestGetContainers(t *testing.T) {
 	bytes := []byte(`{
 		"consul":{
@@ -773,7 +879,8 @@ func TestRelocateContainer(t *testing.T) {
 
 	var calledPath string
 	expectedPath := "/api/v1/ext_app/containers/test-01/schedule_relocation"
-	expectedBody := `{"cluster_name": "cluster-03", "node_hostname": "node-02"}`
+	expectedBody := `{"cluster_name":"cluster-03","node_hostname":"node-02"}`
+	var calledCluster string
 	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		// check content-type
 		contentType := req.Header.Get("Content-type")
@@ -792,13 +899,14 @@ func TestRelocateContainer(t *testing.T) {
 		}
 
 		calledPath = req.URL.Path
+		calledCluster = req.URL.Query().Get("cluster_name")
 		res.WriteHeader(http.StatusOK)
 		res.Write(b)
 	}))
 	defer func() { testServer.Close() }()
 
-	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"RelocateContainer": "api/v1/ext_app/containers"})
-	container, _ := client.RelocateContainer(tables[0].hostname, tables[0].nodeHostname, tables[0].clusterName)
+	client := NewClient(tables[0].clusterName, "", &http.Client{}, testServer.URL, map[string]string{"RelocateContainer": "api/v1/ext_app/containers"})
+	container, _ := client.RelocateContainer(tables[0].hostname, RelocateOptions{NodeHostname: tables[0].nodeHostname})
 
 	if container.NodeHostname != tables[0].nodeHostname {
 		t.Errorf("Incorrect node hostname generated, got: %s, want: %s.",
@@ -812,4 +920,694 @@ func TestRelocateContainer(t *testing.T) {
 			expectedPath)
 	}
 
+	if calledCluster != tables[0].clusterName {
+		t.Errorf("Incorrect cluster_name sent, got: %s, want: %s.",
+			calledCluster,
+			tables[0].clusterName)
+	}
+}
+
+func TestRelocateContainerOptions(t *testing.T) {
+	tables := []struct {
+		opts RelocateOptions
+		body RelocateReq
+	}{
+		{
+			RelocateOptions{},
+			RelocateReq{ClusterName: "default", NodeHostname: AnyNode},
+		},
+		{
+			RelocateOptions{NodeHostname: `node-"02"`, Force: true, Reason: "disk \\ failure"},
+			RelocateReq{ClusterName: "default", NodeHostname: `node-"02"`, Force: true, Reason: "disk \\ failure"},
+		},
+	}
+
+	for _, table := range tables {
+		var gotBody RelocateReq
+		testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			json.NewDecoder(req.Body).Decode(&gotBody)
+			res.WriteHeader(http.StatusOK)
+			res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01"}}`))
+		}))
+
+		client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+		_, err := client.RelocateContainer("test-01", table.opts)
+		testServer.Close()
+
+		if err != nil {
+			t.Errorf("Error when relocating container: %s", err.Error())
+		}
+
+		if gotBody != table.body {
+			t.Errorf("Incorrect body, got: %+v, want: %+v.", gotBody, table.body)
+		}
+	}
+}
+
+func TestCordonNode(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"hostname": "test-01", "ipaddress": "192.168.1.100", "schedulable": false, "cordoned": true}
+	}`)
+
+	var calledPath, calledMethod string
+	expectedPath := "/api/v1/ext_app/nodes/test-01/cordon"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		calledMethod = req.Method
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"CordonNode": "api/v1/ext_app/nodes"})
+	node, _ := client.CordonNode("test-01")
+
+	if !node.Cordoned || node.Schedulable {
+		t.Errorf("Incorrect node schedulability generated, got: schedulable=%t cordoned=%t.", node.Schedulable, node.Cordoned)
+	}
+
+	if expectedPath != calledPath || calledMethod != http.MethodPost {
+		t.Errorf("Incorrect request, got: %s %s, want: %s %s.",
+			calledMethod, calledPath,
+			http.MethodPost, expectedPath)
+	}
+}
+
+func TestUncordonNode(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"hostname": "test-01", "ipaddress": "192.168.1.100", "schedulable": true, "cordoned": false}
+	}`)
+
+	var calledPath string
+	expectedPath := "/api/v1/ext_app/nodes/test-01/uncordon"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"UncordonNode": "api/v1/ext_app/nodes"})
+	node, _ := client.UncordonNode("test-01")
+
+	if node.Cordoned || !node.Schedulable {
+		t.Errorf("Incorrect node schedulability generated, got: schedulable=%t cordoned=%t.", node.Schedulable, node.Cordoned)
+	}
+
+	if expectedPath != calledPath {
+		t.Errorf("Incorrect path called, got: %s, want: %s.", calledPath, expectedPath)
+	}
+}
+
+func TestDeleteNode(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"hostname": "test-01", "ipaddress": "192.168.1.100"}
+	}`)
+
+	var calledPath, calledMethod string
+	expectedPath := "/api/v1/ext_app/nodes/test-01"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		calledMethod = req.Method
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"DeleteNode": "api/v1/ext_app/nodes"})
+	node, _ := client.DeleteNode("test-01")
+
+	if node.Hostname != "test-01" {
+		t.Errorf("Incorrect node hostname generated, got: %s, want: %s.", node.Hostname, "test-01")
+	}
+
+	if expectedPath != calledPath || calledMethod != http.MethodDelete {
+		t.Errorf("Incorrect request, got: %s %s, want: %s %s.",
+			calledMethod, calledPath,
+			http.MethodDelete, expectedPath)
+	}
+}
+
+func TestEvacuateNode(t *testing.T) {
+	cordoned := []byte(`{
+		"api_version": "1.0",
+		"data": {"hostname": "node-01", "schedulable": false, "cordoned": true}
+	}`)
+	nodes := []byte(`{
+		"api_version": "1.0",
+		"data": {
+			"items": [
+				{"hostname": "node-01", "mem_free_mb": 900, "schedulable": false, "cordoned": true},
+				{"hostname": "node-02", "mem_free_mb": 100},
+				{"hostname": "node-03", "mem_free_mb": 500},
+				{"hostname": "node-04", "mem_free_mb": 700, "schedulable": false}
+			]
+		}
+	}`)
+	containers := []byte(`{
+		"api_version": "1.0",
+		"data": {
+			"items": [
+				{"hostname": "test-01", "node_hostname": "node-01", "status": "BOOTSTRAPPED"},
+				{"hostname": "test-02", "node_hostname": "node-02", "status": "BOOTSTRAPPED"},
+				{"hostname": "test-03", "node_hostname": "node-01", "status": "BOOTSTRAPPED"},
+				{"hostname": "test-04", "node_hostname": "node-01", "status": "SCHEDULE_DELETION"}
+			]
+		}
+	}`)
+
+	relocations := map[string]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nodes/node-01/cordon", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(cordoned)
+	})
+	mux.HandleFunc("/nodes", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(nodes)
+	})
+	mux.HandleFunc("/containers", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(containers)
+	})
+	mux.HandleFunc("/containers/", func(res http.ResponseWriter, req *http.Request) {
+		var body struct {
+			NodeHostname string `json:"node_hostname"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		relocations[req.URL.Path] = body.NodeHostname
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {}}`))
+	})
+	testServer := httptest.NewServer(mux)
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"CordonNode":        "nodes",
+		"GetNodes":          "nodes",
+		"GetContainers":     "containers",
+		"RelocateContainer": "containers",
+	})
+	node, err := client.EvacuateNode("node-01")
+	if err != nil {
+		t.Fatalf("Error when evacuating node: %s", err.Error())
+	}
+
+	if !node.Cordoned {
+		t.Errorf("Evacuated node should be cordoned")
+	}
+
+	expectedRelocations := map[string]string{
+		"/containers/test-01/schedule_relocation": "node-03",
+		"/containers/test-03/schedule_relocation": "node-02",
+	}
+	if !reflect.DeepEqual(expectedRelocations, relocations) {
+		t.Errorf("Incorrect relocations, got: %v, want: %v.", relocations, expectedRelocations)
+	}
+}
+
+func TestGetContainersAPIVersion(t *testing.T) {
+	b := []byte(`{
+		"api_version": "2.0",
+		"data": {
+			"items": [
+				{"hostname": "test-c-01", "status": "SCHEDULED"}
+			]
+		}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	containers, err := client.GetContainers()
+	if err != nil || len(*containers) != 1 {
+		t.Errorf("Incompatible versions should only warn by default, got: %v", err)
+	}
+	if client.ServerAPIVersion() != "2.0" {
+		t.Errorf("Incorrect server API version, got: %s, want: %s.", client.ServerAPIVersion(), "2.0")
+	}
+
+	strict := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{}, WithStrictAPIVersion())
+	if _, err := strict.GetContainers(); err == nil {
+		t.Errorf("Strict client should reject incompatible versions")
+	}
+}
+
+func TestTokenProviderRotation(t *testing.T) {
+	validToken := "new-token"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Auth-Token") != validToken {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-c-01"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	token := "old-token"
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{},
+		WithTokenProvider(pfsecret.ProviderFunc(func(context.Context) (string, error) {
+			return token, nil
+		})))
+
+	if _, err := client.GetContainer("test-c-01"); err == nil {
+		t.Errorf("Rejected token should fail when it did not rotate")
+	}
+
+	token = "new-token"
+	container, err := client.GetContainer("test-c-01")
+	if err != nil || container.Hostname != "test-c-01" {
+		t.Errorf("Rotated token should be picked up after a 401, got: %v", err)
+	}
+
+	static := NewClient("default", "static-token", &http.Client{}, testServer.URL, map[string]string{})
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		for _, c := range []Client{client, static} {
+			if out := fmt.Sprintf(format, c); strings.Contains(out, "-token") {
+				t.Errorf("Token leaked with %s, got: %s", format, out)
+			}
+		}
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	calls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-c-01"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	limiter := pflimit.New(pflimit.Config{}, pflimit.Config{Rate: 1, Burst: 1})
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{},
+		WithLimiter(limiter))
+
+	if _, err := client.DeleteContainer("test-c-01"); err != nil {
+		t.Fatalf("Error when deleting container: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.WithContext(ctx).DeleteContainer("test-c-01"); err != context.DeadlineExceeded {
+		t.Errorf("Throttled call should give up with its context, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Throttled call should not reach the server, got %d calls", calls)
+	}
+}
+
+func TestEvacuateNodeWithTracer(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		if req.Method == http.MethodPost {
+			res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "node-01", "cordoned": true}}`))
+			return
+		}
+		res.Write([]byte(`{"api_version": "1.0", "data": {"items": []}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	var spans []pftrace.SpanData
+	tracer := pftrace.NewTracer(pftrace.ExporterFunc(func(data pftrace.SpanData) {
+		spans = append(spans, data)
+	}))
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"CordonNode":    "nodes",
+		"GetContainers": "containers",
+	}, WithTracer(tracer))
+
+	parent, _ := pftrace.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := pftrace.ContextWithSpanContext(context.Background(), parent)
+	if _, err := client.WithContext(ctx).EvacuateNode("node-01"); err != nil {
+		t.Fatalf("Error when evacuating node: %s", err.Error())
+	}
+
+	if len(spans) != 3 {
+		t.Fatalf("Incorrect number of spans, got: %d, want: %d.", len(spans), 3)
+	}
+	operation := spans[2]
+	if operation.Operation != "EvacuateNode" || operation.Parent != parent.SpanID {
+		t.Errorf("EvacuateNode span should be a child of the caller's span, got: %+v", operation)
+	}
+	for _, span := range spans[:2] {
+		if span.Context.TraceID != parent.TraceID || span.Parent != operation.Context.SpanID {
+			t.Errorf("Request span %s should be a child of the EvacuateNode span", span.Operation)
+		}
+	}
+}
+
+func TestGetContainerWithEndpoints(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-c-01"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	deadServer := httptest.NewServer(http.NotFoundHandler())
+	deadServer.Close()
+
+	client := NewClient("default", "", &http.Client{}, deadServer.URL, map[string]string{},
+		WithEndpoints(deadServer.URL, testServer.URL))
+	container, err := client.GetContainer("test-c-01")
+	if err != nil || container.Hostname != "test-c-01" {
+		t.Errorf("Request should fail over to the healthy endpoint, got: %v", err)
+	}
+}
+
+func TestCreateContainerRequestID(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Idempotency-Key") == "" {
+			t.Errorf("Mutating request should carry an idempotency key")
+		}
+		res.Header().Set("X-Request-ID", "server-"+req.Header.Get("X-Request-ID"))
+		res.WriteHeader(http.StatusUnprocessableEntity)
+		res.Write([]byte(`{"error": "hostname has already been taken"}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	_, err := client.CreateContainer(pfmodel.Container{Hostname: "test-c-01"})
+
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Expected *APIError, got: %#v", err)
+	}
+	if apiErr.Message != "hostname has already been taken" || !strings.HasPrefix(apiErr.RequestID, "server-") || len(apiErr.RequestID) <= len("server-") {
+		t.Errorf("Incorrect error, got: %+v", apiErr)
+	}
+}
+
+func TestCreateContainerPinnedIdempotencyKey(t *testing.T) {
+	var keys []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	ctx := ContextWithIdempotencyKey(context.Background(), "create-test-c-01")
+	for i := 0; i < 2; i++ {
+		client.WithContext(ctx).CreateContainer(pfmodel.Container{Hostname: "test-c-01"})
+	}
+	client.CreateContainer(pfmodel.Container{Hostname: "test-c-01"})
+
+	if len(keys) != 3 || keys[0] != keys[1] || !strings.HasPrefix(keys[0], "create-test-c-01") {
+		t.Errorf("Retried call should reuse the pinned key, got: %q", keys)
+	}
+	if keys[2] == keys[0] {
+		t.Errorf("Call without a pinned key should get a fresh one, got: %q", keys[2])
+	}
+}
+
+func TestGetContainerEvents(t *testing.T) {
+	tables := []struct {
+		status         string
+		previousStatus string
+		nodeHostname   string
+		actor          string
+		createdAt      time.Time
+	}{
+		{"PENDING", "", "", "ext", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"SCHEDULED", "PENDING", "node-01", "scheduler", time.Date(2018, 1, 1, 0, 1, 0, 0, time.UTC)},
+		{"BOOTSTRAP_ERROR", "BOOTSTRAP_STARTED", "node-01", "node-01", time.Date(2018, 1, 1, 0, 5, 0, 0, time.UTC)},
+	}
+
+	// Events are sorted by time even when the server does not
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {
+			"items": [
+				{"hostname": "test-01", "status": "SCHEDULED", "previous_status": "PENDING", "node_hostname": "node-01", "actor": "scheduler", "created_at": "2018-01-01T00:01:00Z"},
+				{"hostname": "test-01", "status": "PENDING", "previous_status": "", "node_hostname": "", "actor": "ext", "created_at": "2018-01-01T00:00:00Z"},
+				{"hostname": "test-01", "status": "BOOTSTRAP_ERROR", "previous_status": "BOOTSTRAP_STARTED", "node_hostname": "node-01", "actor": "node-01", "created_at": "2018-01-01T00:05:00Z"}
+			]
+		}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/ext_app/containers/test-01/events" {
+			t.Errorf("Incorrect path, got: %s", req.URL.Path)
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"GetContainerEvents": "api/v1/ext_app/containers",
+	})
+	events, err := client.GetContainerEvents("test-01")
+	if err != nil {
+		t.Fatalf("Error when getting container events: %s", err.Error())
+	}
+	if len(*events) != len(tables) {
+		t.Fatalf("Incorrect number of events, got: %d, want: %d.", len(*events), len(tables))
+	}
+	for i, table := range tables {
+		e := (*events)[i]
+		if e.Hostname != "test-01" {
+			t.Errorf("Incorrect event hostname, got: %s, want: %s.", e.Hostname, "test-01")
+		}
+		if e.Status != table.status || e.PreviousStatus != table.previousStatus {
+			t.Errorf("Incorrect event transition, got: %s -> %s, want: %s -> %s.",
+				e.PreviousStatus, e.Status, table.previousStatus, table.status)
+		}
+		if e.NodeHostname != table.nodeHostname {
+			t.Errorf("Incorrect event node hostname, got: %s, want: %s.", e.NodeHostname, table.nodeHostname)
+		}
+		if e.Actor != table.actor {
+			t.Errorf("Incorrect event actor, got: %s, want: %s.", e.Actor, table.actor)
+		}
+		if !e.CreatedAt.Equal(table.createdAt) {
+			t.Errorf("Incorrect event CreatedAt, got: %s, want: %s.", e.CreatedAt, table.createdAt)
+		}
+	}
+	if i := events.LastWithStatus(pfmodel.StatusBootstrapError); i != 2 {
+		t.Errorf("Incorrect index of last BOOTSTRAP_ERROR, got: %d, want: %d.", i, 2)
+	}
+}
+
+func TestListClusters(t *testing.T) {
+	tables := []struct {
+		name      string
+		createdAt time.Time
+	}{
+		{"default", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"staging", time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {
+			"items": [
+				{"name": "default", "created_at": "2018-01-01T00:00:00Z"},
+				{"name": "staging", "created_at": "2018-02-01T00:00:00Z"}
+			]
+		}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	clusters, _ := client.ListClusters()
+	for i, table := range tables {
+		if (*clusters)[i].Name != table.name {
+			t.Errorf("Incorrect cluster name generated, got: %s, want: %s.",
+				(*clusters)[i].Name,
+				table.name)
+		}
+
+		if !(*clusters)[i].CreatedAt.Equal(table.createdAt) {
+			t.Errorf("Incorrect cluster CreatedAt generated, got: %s, want: %s.",
+				(*clusters)[i].CreatedAt,
+				table.createdAt)
+		}
+	}
+}
+
+func TestGetCluster(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"id": 1, "name": "staging", "created_at": "2018-02-01T00:00:00Z"}
+	}`)
+
+	var calledPath string
+	expectedPath := "/api/v1/ext_app/clusters/staging"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{"GetCluster": "api/v1/ext_app/clusters"})
+	cluster, _ := client.GetCluster("staging")
+
+	if cluster.Name != "staging" {
+		t.Errorf("Incorrect cluster name generated, got: %s, want: %s.", cluster.Name, "staging")
+	}
+
+	if expectedPath != calledPath {
+		t.Errorf("Incorrect path called, got: %s, want: %s.", calledPath, expectedPath)
+	}
+}
+
+func TestCreateCluster(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"id": 2, "name": "staging", "created_at": "2018-02-01T00:00:00Z"}
+	}`)
+
+	var name, password string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		name = req.PostForm.Get("cluster[name]")
+		password = req.PostForm.Get("cluster[password]")
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	cluster, _ := client.CreateCluster(CreateClusterRequest{Name: "staging", Password: "secret"})
+
+	if cluster.Name != "staging" {
+		t.Errorf("Incorrect cluster name generated, got: %s, want: %s.", cluster.Name, "staging")
+	}
+
+	if name != "staging" || password != "secret" {
+		t.Errorf("Incorrect cluster form sent, got: %s/%s, want: %s/%s.", name, password, "staging", "secret")
+	}
+
+	if out := fmt.Sprintf("%v %#v", CreateClusterRequest{Name: "staging", Password: "secret"}, CreateClusterRequest{Password: "secret"}); strings.Contains(out, "secret") {
+		t.Errorf("Cluster password should be redacted, got: %s", out)
+	}
+}
+
+func TestWithCluster(t *testing.T) {
+	b := []byte(`{"api_version": "1.0", "data": {"items": []}}`)
+
+	var clusterName, token string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		clusterName = req.URL.Query().Get("cluster_name")
+		token = req.Header.Get("X-Auth-Token")
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "123", &http.Client{}, testServer.URL, map[string]string{})
+	client.WithCluster("staging").GetNodes()
+
+	if clusterName != "staging" {
+		t.Errorf("Incorrect cluster_name sent, got: %s, want: %s.", clusterName, "staging")
+	}
+
+	if token != "123" {
+		t.Errorf("Incorrect auth token sent, got: %s, want: %s.", token, "123")
+	}
+
+	client.GetNodes()
+	if clusterName != "default" {
+		t.Errorf("Original client should keep its cluster, got: %s, want: %s.", clusterName, "default")
+	}
+}
+
+func TestGetContainersCache(t *testing.T) {
+	b := []byte(`{
+		"api_version": "1.0",
+		"data": {"items": [{"hostname": "test-01", "status": "SCHEDULED"}]}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	cache := pfcache.New()
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{}, WithCache(cache))
+
+	first, _ := client.GetContainers()
+	(*first)[0].Status = "MUTATED"
+
+	second, err := client.GetContainers()
+	if err != nil {
+		t.Fatalf("Error when getting cached containers: %s", err.Error())
+	}
+
+	if len(*second) != 1 || (*second)[0].Status != "SCHEDULED" {
+		t.Errorf("Incorrect cached containers, got: %+v", *second)
+	}
+
+	if cache.Hits() != 1 || cache.Misses() != 1 {
+		t.Errorf("Incorrect cache counters, got: %+v", cache.Stats())
+	}
+}
+
+func TestGetNodesAndContainersCacheCopies(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.WriteHeader(http.StatusOK)
+		if req.URL.Path == "/nodes" {
+			res.Write([]byte(`{"api_version": "1.0", "data": {"items": [
+				{"hostname": "node-01", "metrics": {"memory": {"used": 100, "free": 100, "total": 200}}}
+			]}}`))
+			return
+		}
+		res.Write([]byte(`{"api_version": "1.0", "data": {"items": [
+			{"hostname": "test-01", "bootstrappers": [{"bootstrap_type": "chef-solo", "bootstrap_attributes": {"run_list": ["role[consul]"]}}]}
+		]}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	client := NewClient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"GetNodes":      "nodes",
+		"GetContainers": "containers",
+	}, WithCache(pfcache.New()))
+
+	nodes, _ := client.GetNodes()
+	(*nodes)[0].Metrics.Memory.Used = 0
+	nodes, err := client.GetNodes()
+	if err != nil {
+		t.Fatalf("Error when getting cached nodes: %s", err.Error())
+	}
+	if used := (*nodes)[0].Metrics.Memory.Used; used != 100 {
+		t.Errorf("Incorrect cached node memory, got: %d, want: %d.", used, 100)
+	}
+
+	containers, _ := client.GetContainers()
+	attributes := (*containers)[0].Bootstrappers[0].Attributes.(map[string]interface{})
+	attributes["run_list"].([]interface{})[0] = "role[mutated]"
+	containers, err = client.GetContainers()
+	if err != nil {
+		t.Fatalf("Error when getting cached containers: %s", err.Error())
+	}
+	attributes = (*containers)[0].Bootstrappers[0].Attributes.(map[string]interface{})
+	if role := attributes["run_list"].([]interface{})[0]; role != "role[consul]" {
+		t.Errorf("Incorrect cached bootstrap attributes, got: %v, want: %s.", role, "role[consul]")
+	}
 }
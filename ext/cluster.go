@@ -0,0 +1,54 @@
+package ext
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
+)
+
+// CreateClusterRequest describes a cluster to create. Its password is only
+// sent to the server and never part of the pfmodel.Cluster read back.
+type CreateClusterRequest struct {
+	Name     string
+	Password string
+}
+
+// String keeps the password out of logs and %v output.
+func (r CreateClusterRequest) String() string {
+	return fmt.Sprintf("{Name: %s, Password: %s}", r.Name, pfsecret.Redacted)
+}
+
+func (r CreateClusterRequest) GoString() string {
+	return r.String()
+}
+
+type ClusterRes struct {
+	ApiVersion string         `json:"api_version"`
+	Data       ClusterDataRes `json:"data"`
+}
+
+type ClusterDataRes struct {
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+func NewClusterFromByte(b []byte) (*pfmodel.Cluster, error) {
+	var res ClusterRes
+	err := json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClusterFromRes(res.Data), nil
+}
+
+func newClusterFromRes(c ClusterDataRes) *pfmodel.Cluster {
+	cluster := pfmodel.Cluster{
+		Name:      c.Name,
+		CreatedAt: parseTime(c.CreatedAt),
+	}
+
+	return &cluster
+}
@@ -0,0 +1,31 @@
+package ext
+
+import (
+	"encoding/json"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+type ClusterListRes struct {
+	ApiVersion string             `json:"api_version"`
+	Data       ClusterListDataRes `json:"data"`
+}
+
+type ClusterListDataRes struct {
+	Items []ClusterDataRes `json:"items"`
+}
+
+func NewClusterListFromByte(b []byte) (*pfmodel.ClusterList, error) {
+	var res ClusterListRes
+	err := json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make(pfmodel.ClusterList, len(res.Data.Items))
+	for i, c := range res.Data.Items {
+		clusters[i] = *newClusterFromRes(c)
+	}
+
+	return &clusters, nil
+}
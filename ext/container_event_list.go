@@ -0,0 +1,50 @@
+package ext
+
+import (
+	"encoding/json"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+type ContainerEventListRes struct {
+	ApiVersion string                    `json:"api_version"`
+	Data       ContainerEventListDataRes `json:"data"`
+}
+
+type ContainerEventListDataRes struct {
+	Items []ContainerEventDataRes `json:"items"`
+}
+
+type ContainerEventDataRes struct {
+	Hostname       string `json:"hostname"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+	NodeHostname   string `json:"node_hostname"`
+	Actor          string `json:"actor"`
+	Message        string `json:"message"`
+	CreatedAt      string `json:"created_at"`
+}
+
+func NewContainerEventListFromByte(b []byte) (*pfmodel.ContainerEventList, error) {
+	var res ContainerEventListRes
+	err := json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(pfmodel.ContainerEventList, len(res.Data.Items))
+	for i, e := range res.Data.Items {
+		events[i] = pfmodel.ContainerEvent{
+			Hostname:       e.Hostname,
+			Status:         e.Status,
+			PreviousStatus: e.PreviousStatus,
+			NodeHostname:   e.NodeHostname,
+			Actor:          e.Actor,
+			Message:        e.Message,
+			CreatedAt:      parseTime(e.CreatedAt),
+		}
+	}
+	events.SortByTime()
+
+	return &events, nil
+}
@@ -2,7 +2,9 @@ package ext
 
 import (
 	"encoding/json"
+	"io"
 
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/decode"
 	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
 )
 
@@ -46,3 +48,25 @@ func NewContainerListFromByte(b []byte) (*pfmodel.ContainerList, error) {
 
 	return &containers, nil
 }
+
+// NewContainerListFromReader decodes a container list response while reading
+// it, without buffering the raw body or copying the decoded items.
+func NewContainerListFromReader(r io.Reader) (*pfmodel.ContainerList, error) {
+	cl := pfmodel.ContainerList{}
+	err := DecodeContainerList(r, func(c pfmodel.Container) error {
+		cl = append(cl, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &cl, nil
+}
+
+// DecodeContainerList calls fn for each container of a container list
+// response as soon as it is read from r.
+func DecodeContainerList(r io.Reader, fn func(pfmodel.Container) error) error {
+	_, err := decode.ContainerList(r, fn)
+	return err
+}
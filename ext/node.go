@@ -12,12 +12,17 @@ type NodeRes struct {
 }
 
 type NodeDataRes struct {
-	Hostname   string `json:"hostname"`
-	Ipaddress  string `json:"ipaddress"`
-	CreatedAt  string `json:"created_at"`
-	MemFreeMb  uint64 `json:"mem_free_mb"`
-	MemUsedMb  uint64 `json:"mem_used_mb"`
-	MemTotalMb uint64 `json:"mem_total_mb"`
+	Hostname         string          `json:"hostname"`
+	Ipaddress        string          `json:"ipaddress"`
+	CreatedAt        string          `json:"created_at"`
+	MemFreeMb        uint64          `json:"mem_free_mb"`
+	MemUsedMb        uint64          `json:"mem_used_mb"`
+	MemTotalMb       uint64          `json:"mem_total_mb"`
+	Metrics          pfmodel.Metrics `json:"metrics"`
+	LastHeartbeatAt  string          `json:"last_heartbeat_at"`
+	MetricsUpdatedAt string          `json:"metrics_updated_at"`
+	Schedulable      *bool           `json:"schedulable"`
+	Cordoned         bool            `json:"cordoned"`
 }
 
 func NewNodeFromByte(b []byte) (*pfmodel.Node, error) {
@@ -27,14 +32,29 @@ func NewNodeFromByte(b []byte) (*pfmodel.Node, error) {
 		return nil, err
 	}
 
+	return newNodeFromRes(res.Data), nil
+}
+
+func newNodeFromRes(n NodeDataRes) *pfmodel.Node {
+	// Servers that predate the schedulable flag only know about cordoning
+	schedulable := !n.Cordoned
+	if n.Schedulable != nil {
+		schedulable = *n.Schedulable
+	}
+
 	node := pfmodel.Node{
-		Hostname:   res.Data.Hostname,
-		Ipaddress:  res.Data.Ipaddress,
-		CreatedAt:  res.Data.CreatedAt,
-		MemFreeMb:  res.Data.MemFreeMb,
-		MemUsedMb:  res.Data.MemUsedMb,
-		MemTotalMb: res.Data.MemTotalMb,
+		Hostname:         n.Hostname,
+		Ipaddress:        n.Ipaddress,
+		CreatedAt:        parseTime(n.CreatedAt),
+		MemFreeMb:        n.MemFreeMb,
+		MemUsedMb:        n.MemUsedMb,
+		MemTotalMb:       n.MemTotalMb,
+		Metrics:          n.Metrics,
+		LastHeartbeatAt:  parseTime(n.LastHeartbeatAt),
+		MetricsUpdatedAt: parseTime(n.MetricsUpdatedAt),
+		Schedulable:      schedulable,
+		Cordoned:         n.Cordoned,
 	}
 
-	return &node, nil
+	return &node
 }
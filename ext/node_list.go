@@ -16,12 +16,17 @@ type NodeListDataRes struct {
 }
 
 type NodeListItemRes struct {
-	Hostname   string `json:"hostname"`
-	Ipaddress  string `json:"ipaddress"`
-	CreatedAt  string `json:"created_at"`
-	MemFreeMb  uint64 `json:"mem_free_mb"`
-	MemUsedMb  uint64 `json:"mem_used_mb"`
-	MemTotalMb uint64 `json:"mem_total_mb"`
+	Hostname         string          `json:"hostname"`
+	Ipaddress        string          `json:"ipaddress"`
+	CreatedAt        string          `json:"created_at"`
+	MemFreeMb        uint64          `json:"mem_free_mb"`
+	MemUsedMb        uint64          `json:"mem_used_mb"`
+	MemTotalMb       uint64          `json:"mem_total_mb"`
+	Metrics          pfmodel.Metrics `json:"metrics"`
+	LastHeartbeatAt  string          `json:"last_heartbeat_at"`
+	MetricsUpdatedAt string          `json:"metrics_updated_at"`
+	Schedulable      *bool           `json:"schedulable"`
+	Cordoned         bool            `json:"cordoned"`
 }
 
 func NewNodeListFromByte(b []byte) (*pfmodel.NodeList, error) {
@@ -33,14 +38,7 @@ func NewNodeListFromByte(b []byte) (*pfmodel.NodeList, error) {
 
 	nodes := make(pfmodel.NodeList, len(res.Data.Items))
 	for i, n := range res.Data.Items {
-		nodes[i] = pfmodel.Node{
-			Hostname:   n.Hostname,
-			Ipaddress:  n.Ipaddress,
-			CreatedAt:  n.CreatedAt,
-			MemFreeMb:  n.MemFreeMb,
-			MemUsedMb:  n.MemUsedMb,
-			MemTotalMb: n.MemTotalMb,
-		}
+		nodes[i] = *newNodeFromRes(NodeDataRes(n))
 	}
 
 	return &nodes, nil
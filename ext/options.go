@@ -0,0 +1,105 @@
+package ext
+
+import (
+	"crypto/tls"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfcache"
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftls"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftrace"
+)
+
+type Option func(*client)
+
+// WithCache revalidates fetched container and node lists with conditional
+// requests and reuses the cached lists when the server answers 304 Not
+// Modified.
+func WithCache(cache *pfcache.Cache) Option {
+	return func(c *client) {
+		c.tr.Cache = cache
+	}
+}
+
+// WithStrictAPIVersion makes requests fail with an error when the server
+// reports an API major version the client does not support, instead of
+// logging a warning and decoding the response anyway.
+func WithStrictAPIVersion() Option {
+	return func(c *client) {
+		c.versions.Strict = true
+	}
+}
+
+// WithTLSConfig makes the client talk TLS with tlsConfig, usually built with
+// pftls.Load. It works on a copy of the HTTP client given to the
+// constructor, which is left untouched. When the HTTP client does not use
+// an *http.Transport, every request fails with pftls.ErrUnsupportedTransport.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *client) {
+		httpClient, err := pftls.Apply(c.httpClient, tlsConfig)
+		if err != nil {
+			c.tr.Err = err
+			return
+		}
+		c.httpClient = httpClient
+		c.tr.HTTPClient = httpClient
+	}
+}
+
+// WithTokenProvider reads the API token from provider on first use instead
+// of using the one given to the constructor. The token is read again when
+// the server rejects it, so rotated tokens are picked up.
+func WithTokenProvider(provider pfsecret.Provider) Option {
+	return func(c *client) {
+		c.tokenSource = pfsecret.NewSource(provider)
+	}
+}
+
+// WithEndpoints spreads requests over several Pathfinder server replicas
+// instead of the single address given to the constructor. Requests stick to
+// the replica that last answered and fail over to the next one on
+// connection errors and 5xx responses.
+func WithEndpoints(addrs ...string) Option {
+	return func(c *client) {
+		if len(addrs) == 0 {
+			return
+		}
+		c.pfServerAddr = addrs[0]
+		c.tr.SetEndpoints(addrs)
+	}
+}
+
+// WithHealthCheckPath makes replicas that failed be probed on path, e.g.
+// "healthz", before requests are sent to them again.
+func WithHealthCheckPath(path string) Option {
+	return func(c *client) {
+		c.tr.HealthPath = path
+	}
+}
+
+// WithLimiter throttles requests with limiter, waiting for it before each
+// request until the request's context is done. A limiter shared between
+// clients throttles them together.
+func WithLimiter(limiter *pflimit.Limiter) Option {
+	return func(c *client) {
+		c.tr.Limiter = limiter
+	}
+}
+
+// WithBreaker stops sending requests while breaker is open, failing them
+// with ErrCircuitOpen instead. Connection errors and 5xx responses count as
+// failures.
+func WithBreaker(breaker *pfbreaker.Breaker) Option {
+	return func(c *client) {
+		c.tr.Breaker = breaker
+	}
+}
+
+// WithTracer creates a span named after the client method for every request
+// and sends it to the server in the traceparent and tracestate headers.
+func WithTracer(tracer pftrace.Tracer) Option {
+	return func(c *client) {
+		c.tr.Tracer = tracer
+	}
+}
@@ -0,0 +1,32 @@
+package ext
+
+// AnyNode lets Pathfinder server pick the relocation target.
+const AnyNode = "any"
+
+type RelocateOptions struct {
+	// NodeHostname is the target node, empty or AnyNode for any node.
+	NodeHostname string
+	Force        bool
+	Reason       string
+}
+
+type RelocateReq struct {
+	ClusterName  string `json:"cluster_name"`
+	NodeHostname string `json:"node_hostname"`
+	Force        bool   `json:"force,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+func newRelocateReq(cluster string, opts RelocateOptions) RelocateReq {
+	nodeHostname := opts.NodeHostname
+	if nodeHostname == "" {
+		nodeHostname = AnyNode
+	}
+
+	return RelocateReq{
+		ClusterName:  cluster,
+		NodeHostname: nodeHostname,
+		Force:        opts.Force,
+		Reason:       opts.Reason,
+	}
+}
@@ -0,0 +1,34 @@
+package ext
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// parseTime accepts the timestamp formats emitted by Pathfinder server.
+// An empty or unrecognized string yields the zero time, the latter being
+// logged rather than failing the whole response.
+func parseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	log.Warnf("Unable to parse timestamp %q: %s", s, err.Error())
+	return time.Time{}
+}
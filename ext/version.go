@@ -0,0 +1,57 @@
+package ext
+
+import (
+	"io"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/decode"
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/version"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+// decoderSet holds the response decoders for one major API version.
+type decoderSet struct {
+	node          func([]byte) (*pfmodel.Node, error)
+	nodeList      func([]byte) (*pfmodel.NodeList, error)
+	container     func([]byte) (*pfmodel.Container, error)
+	containerList version.ContainerListDecoder
+	cluster       func([]byte) (*pfmodel.Cluster, error)
+	clusterList   func([]byte) (*pfmodel.ClusterList, error)
+	eventList     func([]byte) (*pfmodel.ContainerEventList, error)
+}
+
+// decoderSets is keyed by major API version. Servers reporting a major
+// without its own set are decoded with the supported one.
+var decoderSets = version.Sets{
+	1: &decoderSet{
+		node:          NewNodeFromByte,
+		nodeList:      NewNodeListFromByte,
+		container:     NewContainerFromByte,
+		containerList: decode.ContainerList,
+		cluster:       NewClusterFromByte,
+		clusterList:   NewClusterListFromByte,
+		eventList:     NewContainerEventListFromByte,
+	},
+}
+
+// ServerAPIVersion returns the api_version last reported by the server, or
+// an empty string before the first response.
+func (c *client) ServerAPIVersion() string {
+	return c.versions.Server()
+}
+
+func (c *client) decoders() *decoderSet {
+	return c.versions.Select(decoderSets).(*decoderSet)
+}
+
+// decodersFor observes the api_version of the response body b and returns
+// the decoder set matching it.
+func (c *client) decodersFor(b []byte) (*decoderSet, error) {
+	if err := c.versions.ObserveBody(b); err != nil {
+		return nil, err
+	}
+	return c.decoders(), nil
+}
+
+func (c *client) readContainerList(r io.Reader) (*pfmodel.ContainerList, error) {
+	return c.versions.ReadContainerList(r, c.decoders().containerList)
+}
@@ -0,0 +1,104 @@
+// Package decode walks Pathfinder response envelopes with a streaming JSON
+// decoder so large lists never have to be held in memory twice.
+package decode
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+// ContainerList reads a container list response from r and calls fn with
+// each item as soon as it is decoded. It returns the envelope's api_version.
+func ContainerList(r io.Reader, fn func(pfmodel.Container) error) (string, error) {
+	dec := json.NewDecoder(r)
+
+	var apiVersion string
+	// Items are decoded into the same zeroed value, copied out to fn, so
+	// they do not cost a heap allocation each.
+	c := new(pfmodel.Container)
+	err := object(dec, func(key string) error {
+		switch key {
+		case "api_version":
+			return dec.Decode(&apiVersion)
+		case "data":
+			return object(dec, func(key string) error {
+				if key != "items" {
+					return skip(dec)
+				}
+				return array(dec, func() error {
+					*c = pfmodel.Container{}
+					if err := dec.Decode(c); err != nil {
+						return err
+					}
+					return fn(*c)
+				})
+			})
+		default:
+			return skip(dec)
+		}
+	})
+
+	return apiVersion, err
+}
+
+// object calls fn for every key of the next JSON object, leaving the decoder
+// positioned at the key's value. A null is treated as an empty object.
+func object(dec *json.Decoder, fn func(key string) error) error {
+	ok, err := open(dec, '{')
+	if err != nil || !ok {
+		return err
+	}
+
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := fn(t.(string)); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// array calls fn for every element of the next JSON array, leaving the
+// decoder positioned at the element. A null is treated as an empty array.
+func array(dec *json.Decoder, fn func() error) error {
+	ok, err := open(dec, '[')
+	if err != nil || !ok {
+		return err
+	}
+
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+func open(dec *json.Decoder, delim json.Delim) (bool, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if t == nil {
+		return false, nil
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return false, fmt.Errorf("unexpected %v in response, want %v", t, delim)
+	}
+	return true, nil
+}
+
+func skip(dec *json.Decoder) error {
+	var v json.RawMessage
+	return dec.Decode(&v)
+}
@@ -0,0 +1,73 @@
+package decode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+func TestContainerList(t *testing.T) {
+	tables := []struct {
+		body       string
+		hostnames  []string
+		apiVersion string
+	}{
+		{
+			`{"api_version": "1.0", "data": {"items": [{"hostname": "test-01"}, {"hostname": "test-02"}]}}`,
+			[]string{"test-01", "test-02"},
+			"1.0",
+		},
+		{
+			`{"data": {"total": 1, "items": [{"hostname": "test-01", "extra": {"a": [1, 2]}}], "page": {"next": null}}, "api_version": "2.0"}`,
+			[]string{"test-01"},
+			"2.0",
+		},
+		{
+			`{"api_version": "1.0", "data": {"items": null}}`,
+			nil,
+			"1.0",
+		},
+		{
+			`{"api_version": "1.0", "data": null}`,
+			nil,
+			"1.0",
+		},
+	}
+
+	for _, table := range tables {
+		var hostnames []string
+		apiVersion, err := ContainerList(strings.NewReader(table.body), func(c pfmodel.Container) error {
+			hostnames = append(hostnames, c.Hostname)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Error when decoding %s: %s", table.body, err.Error())
+			continue
+		}
+
+		if apiVersion != table.apiVersion {
+			t.Errorf("Incorrect api version decoded, got: %s, want: %s.", apiVersion, table.apiVersion)
+		}
+
+		if strings.Join(hostnames, ",") != strings.Join(table.hostnames, ",") {
+			t.Errorf("Incorrect containers decoded, got: %v, want: %v.", hostnames, table.hostnames)
+		}
+	}
+}
+
+func TestContainerListInvalid(t *testing.T) {
+	bodies := []string{
+		`[]`,
+		`{"data": {"items": {}}}`,
+		`{"data": {"items": [{"hostname": 1}]}}`,
+		`{"data": {"items": [`,
+	}
+
+	for _, body := range bodies {
+		_, err := ContainerList(strings.NewReader(body), func(c pfmodel.Container) error { return nil })
+		if err == nil {
+			t.Errorf("Decoding %s should fail", body)
+		}
+	}
+}
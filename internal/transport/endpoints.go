@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// endpointCooldown is how long an endpoint that failed is avoided before
+// being probed or tried again.
+const endpointCooldown = 10 * time.Second
+
+type endpoint struct {
+	addr      string
+	downUntil time.Time
+	probing   bool
+}
+
+// endpoints routes requests to one of several server replicas, sticking to
+// the last one that answered and moving to the next on failure.
+type endpoints struct {
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	list      []*endpoint
+	preferred int
+}
+
+// SetEndpoints makes t spread requests over addrs instead of Addr. When
+// t.HealthPath is set, endpoints that failed are probed on it before being
+// used again.
+func (t *Transport) SetEndpoints(addrs []string) {
+	if len(addrs) == 0 {
+		return
+	}
+
+	e := &endpoints{cooldown: endpointCooldown}
+	for _, addr := range addrs {
+		e.list = append(e.list, &endpoint{addr: addr})
+	}
+	t.Addr = addrs[0]
+	t.endpoints = e
+}
+
+// order returns the addresses to try: the preferred endpoint, then the
+// other available ones, then those still cooling down as a last resort.
+func (e *endpoints) order(t *Transport) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	var up, down []string
+	for i := range e.list {
+		ep := e.list[(e.preferred+i)%len(e.list)]
+		switch {
+		case now.After(ep.downUntil) && !ep.probing && t.HealthPath != "" && !ep.downUntil.IsZero():
+			ep.probing = true
+			go e.probe(t, ep)
+			down = append(down, ep.addr)
+		case now.After(ep.downUntil) && !ep.probing:
+			up = append(up, ep.addr)
+		default:
+			down = append(down, ep.addr)
+		}
+	}
+	return append(up, down...)
+}
+
+func (e *endpoints) succeeded(addr string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, ep := range e.list {
+		if ep.addr == addr {
+			ep.downUntil = time.Time{}
+			e.preferred = i
+		}
+	}
+}
+
+func (e *endpoints) failed(addr string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, ep := range e.list {
+		if ep.addr == addr {
+			ep.downUntil = time.Now().Add(e.cooldown)
+		}
+	}
+}
+
+// probe checks the health endpoint of ep and puts it back in rotation when
+// it answers, or keeps it cooling down otherwise.
+func (e *endpoints) probe(t *Transport, ep *endpoint) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cooldown)
+	defer cancel()
+
+	healthy := false
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", ep.addr, t.HealthPath), nil)
+	if err == nil {
+		var res *http.Response
+		res, err = t.HTTPClient.Do(req.WithContext(ctx))
+		if err == nil {
+			healthy = res.StatusCode >= 200 && res.StatusCode < 300
+			Close(res)
+		}
+	}
+	if !healthy {
+		log.Warnf("Pathfinder endpoint %s is still unhealthy", ep.addr)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ep.probing = false
+	if healthy {
+		ep.downUntil = time.Time{}
+	} else {
+		ep.downUntil = time.Now().Add(e.cooldown)
+	}
+}
@@ -0,0 +1,122 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingServer(status *int32, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+	}))
+}
+
+func TestEndpointsFailover(t *testing.T) {
+	primaryStatus, secondaryStatus := int32(http.StatusOK), int32(http.StatusOK)
+	var primaryHits, secondaryHits int32
+	primary := newCountingServer(&primaryStatus, &primaryHits)
+	defer primary.Close()
+	secondary := newCountingServer(&secondaryStatus, &secondaryHits)
+	defer secondary.Close()
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	tr := New(&http.Client{}, "")
+	tr.SetEndpoints([]string{dead.URL, primary.URL, secondary.URL})
+
+	for i := 0; i < 3; i++ {
+		if err := tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if atomic.LoadInt32(&primaryHits) != 3 {
+		t.Errorf("Requests should stick to the first healthy endpoint, got %d hits", primaryHits)
+	}
+
+	atomic.StoreInt32(&primaryStatus, http.StatusBadGateway)
+	for i := 0; i < 2; i++ {
+		if err := tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil); err != nil {
+			t.Fatalf("5xx should fail over transparently, got: %s", err.Error())
+		}
+	}
+	if atomic.LoadInt32(&primaryHits) != 4 || atomic.LoadInt32(&secondaryHits) != 2 {
+		t.Errorf("Incorrect failover, got %d primary and %d secondary hits", primaryHits, secondaryHits)
+	}
+
+	atomic.StoreInt32(&secondaryStatus, http.StatusServiceUnavailable)
+	err := tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode < http.StatusInternalServerError {
+		t.Errorf("Last failure should be returned when every endpoint fails, got: %v", err)
+	}
+}
+
+func TestEndpointsHealthCheck(t *testing.T) {
+	status := int32(http.StatusServiceUnavailable)
+	var hits int32
+	primary := newCountingServer(&status, &hits)
+	defer primary.Close()
+
+	var secondaryHits int32
+	secondaryStatus := int32(http.StatusOK)
+	secondary := newCountingServer(&secondaryStatus, &secondaryHits)
+	defer secondary.Close()
+
+	tr := New(&http.Client{}, "")
+	tr.HealthPath = "healthz"
+	tr.SetEndpoints([]string{primary.URL, secondary.URL})
+	tr.endpoints.cooldown = 10 * time.Millisecond
+
+	tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+	if atomic.LoadInt32(&secondaryHits) != 1 {
+		t.Fatalf("Request should fail over to the secondary endpoint")
+	}
+
+	// The primary recovers, gets probed once its cooldown is over, but the
+	// client sticks to the secondary endpoint
+	atomic.StoreInt32(&status, http.StatusOK)
+	time.Sleep(20 * time.Millisecond)
+	hitsBefore := atomic.LoadInt32(&hits)
+	tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&hits) == hitsBefore && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadInt32(&hits) != hitsBefore+1 {
+		t.Errorf("Recovered endpoint should be probed once, got %d hits", atomic.LoadInt32(&hits)-hitsBefore)
+	}
+	if atomic.LoadInt32(&secondaryHits) != 2 {
+		t.Errorf("Requests should stick to the secondary endpoint, got %d hits", secondaryHits)
+	}
+}
+
+func TestEndpointsFailoverReusesIdempotencyKey(t *testing.T) {
+	var keys, requestIDs []string
+	record := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+			requestIDs = append(requestIDs, r.Header.Get(RequestIDHeader))
+			w.WriteHeader(status)
+		}
+	}
+	failing := httptest.NewServer(record(http.StatusBadGateway))
+	defer failing.Close()
+	healthy := httptest.NewServer(record(http.StatusOK))
+	defer healthy.Close()
+
+	tr := New(&http.Client{}, "")
+	tr.SetEndpoints([]string{failing.URL, healthy.URL})
+	if err := tr.Do(context.Background(), &Request{Method: http.MethodPost}, nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] || requestIDs[0] != requestIDs[1] {
+		t.Errorf("Retries should reuse the idempotency key and request id, got: %v %v", keys, requestIDs)
+	}
+}
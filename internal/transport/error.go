@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const maxErrorBody = 1 << 20
+
+// Error is returned when Pathfinder server answers with an unexpected
+// status.
+type Error struct {
+	StatusCode int
+	Message    string
+	Body       []byte
+	// RequestID identifies the request to the server operators, as echoed
+	// by the server or else as sent.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError reads the body of res and extracts the server's message from it.
+func NewError(res *http.Response) *Error {
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	requestID := res.Header.Get(RequestIDHeader)
+	if requestID == "" && res.Request != nil {
+		requestID = res.Request.Header.Get(RequestIDHeader)
+	}
+
+	return &Error{
+		StatusCode: res.StatusCode,
+		Message:    errorMessage(res.StatusCode, b),
+		Body:       b,
+		RequestID:  requestID,
+	}
+}
+
+// errorMessage understands {"error": "..."}, {"error": {"message": "..."}}
+// and {"message": "..."} bodies, and otherwise uses the body as is.
+func errorMessage(statusCode int, b []byte) string {
+	var res struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(b, &res) == nil {
+		var msg string
+		if json.Unmarshal(res.Error, &msg) == nil && msg != "" {
+			return msg
+		}
+
+		var obj struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(res.Error, &obj) == nil && obj.Message != "" {
+			return obj.Message
+		}
+
+		if res.Message != "" {
+			return res.Message
+		}
+	}
+
+	if msg := strings.TrimSpace(string(b)); msg != "" {
+		return msg
+	}
+	return http.StatusText(statusCode)
+}
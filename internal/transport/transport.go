@@ -0,0 +1,399 @@
+// Package transport holds the request plumbing shared by pfclient and ext:
+// building URLs, setting headers, checking statuses and decoding errors,
+// while making sure every response body is drained and closed so
+// connections are reused.
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfcache"
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftrace"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormContentType = "application/x-www-form-urlencoded"
+	JSONContentType = "application/json"
+
+	RequestIDHeader      = "X-Request-ID"
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// maxDrain bounds how much of an unread body is consumed to let the
+	// connection be reused.
+	maxDrain = 64 << 10
+)
+
+type Request struct {
+	// Operation names the client call the request belongs to, for tracing.
+	Operation string
+	Method    string
+	// Path is appended to the server address after a slash.
+	Path        string
+	Query       url.Values
+	Header      http.Header
+	Token       string
+	Body        []byte
+	ContentType string
+	// LongPoll marks requests the server may hold open, such as watches.
+	// They are rate limited but do not keep an in-flight slot of the
+	// limiter, which would starve the other requests of their class.
+	LongPoll bool
+	// RequestID and IdempotencyKey are generated on first send when empty,
+	// the latter only for mutating methods, and then kept for retries. See
+	// ContextWithIdempotencyKey to pin the key across calls.
+	RequestID      string
+	IdempotencyKey string
+}
+
+type Transport struct {
+	HTTPClient *http.Client
+	Addr       string
+	Cache      *pfcache.Cache
+	// HealthPath is probed on endpoints that failed, see SetEndpoints.
+	HealthPath string
+	Limiter    *pflimit.Limiter
+	Breaker    *pfbreaker.Breaker
+	Tracer     pftrace.Tracer
+	// Err, when set, fails every request without sending it. It records
+	// options that could not be applied, such as TLS settings, so the
+	// client never talks to the server without them.
+	Err error
+
+	endpoints *endpoints
+}
+
+func New(httpClient *http.Client, addr string) *Transport {
+	return &Transport{
+		HTTPClient: httpClient,
+		Addr:       addr,
+	}
+}
+
+// Send performs r and returns the raw response, whatever its status. The
+// caller must close the response body, preferably with Close.
+func (t *Transport) Send(ctx context.Context, r *Request) (*http.Response, error) {
+	return t.send(ctx, r, nil)
+}
+
+type operationKey struct{}
+
+// StartOperation opens a span for a client call made of several requests,
+// such as one retried with a refreshed credential, so their spans share it
+// as parent. The returned end must be called with the outcome of the call.
+// Without a tracer it does nothing.
+func (t *Transport) StartOperation(ctx context.Context, operation string) (context.Context, func(error)) {
+	if t.Tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := t.Tracer.Start(ctx, operation)
+	return context.WithValue(ctx, operationKey{}, operation), span.End
+}
+
+// send performs r, letting prepare adjust each outgoing request. With a
+// tracer, the request gets a span propagated in its traceparent header,
+// named after r.Operation unless it is sent on behalf of an operation span
+// of that name.
+func (t *Transport) send(ctx context.Context, r *Request, prepare func(*http.Request)) (*http.Response, error) {
+	if t.Err != nil {
+		return nil, t.Err
+	}
+	if r.RequestID == "" {
+		r.RequestID = newID()
+	}
+	if r.IdempotencyKey == "" && mutating(r.Method) {
+		r.IdempotencyKey = idempotencyKeyFor(ctx, r)
+	}
+
+	if t.Tracer == nil {
+		return t.sendChecked(ctx, r, prepare)
+	}
+
+	operation := r.Operation
+	if operation == "" || operation == ctx.Value(operationKey{}) {
+		operation = r.Method + " " + r.Path
+	}
+	ctx, span := t.Tracer.Start(ctx, operation)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.request_id", r.RequestID)
+	span.SetAttribute("http.path", r.Path)
+
+	res, err := t.sendChecked(ctx, r, func(req *http.Request) {
+		pftrace.Inject(span.Context(), req.Header)
+		if prepare != nil {
+			prepare(req)
+		}
+	})
+	if err == nil {
+		span.SetAttribute("http.status_code", res.StatusCode)
+		span.SetAttribute("net.peer.name", res.Request.URL.Host)
+		if res.StatusCode >= http.StatusBadRequest {
+			span.End(fmt.Errorf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)))
+			return res, err
+		}
+	}
+	span.End(err)
+
+	return res, err
+}
+
+// sendChecked goes through the breaker and the limiter before sending r.
+// The limiter slot is held until the response headers arrived, except for
+// long-poll requests.
+func (t *Transport) sendChecked(ctx context.Context, r *Request, prepare func(*http.Request)) (*http.Response, error) {
+	report, err := t.Breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := t.Limiter.Wait(ctx, r.Method)
+	if err != nil {
+		report(pfbreaker.Ignored)
+		return nil, err
+	}
+	if r.LongPoll {
+		release()
+	} else {
+		defer release()
+	}
+
+	res, err := t.sendToEndpoints(ctx, r, prepare)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		report(pfbreaker.Ignored)
+	case err != nil || res.StatusCode >= http.StatusInternalServerError:
+		report(pfbreaker.Failure)
+	default:
+		report(pfbreaker.Success)
+	}
+	return res, err
+}
+
+// sendToEndpoints sends r to the current endpoint. With several endpoints,
+// connection errors and 5xx responses are retried on the next endpoint, and
+// the last outcome is returned when all of them fail.
+func (t *Transport) sendToEndpoints(ctx context.Context, r *Request, prepare func(*http.Request)) (*http.Response, error) {
+	if t.endpoints == nil {
+		return t.sendTo(ctx, t.Addr, r, prepare)
+	}
+
+	var res *http.Response
+	var err error
+	addrs := t.endpoints.order(t)
+	for i, addr := range addrs {
+		res, err = t.sendTo(ctx, addr, r, prepare)
+		if err == nil && res.StatusCode < http.StatusInternalServerError {
+			t.endpoints.succeeded(addr)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		t.endpoints.failed(addr)
+		if i == len(addrs)-1 {
+			break
+		}
+		if err != nil {
+			log.Warnf("Pathfinder endpoint %s failed, trying the next one: %s", addr, err.Error())
+		} else {
+			log.Warnf("Pathfinder endpoint %s answered %d, trying the next one", addr, res.StatusCode)
+			Close(res)
+		}
+	}
+
+	return res, err
+}
+
+func (t *Transport) sendTo(ctx context.Context, addr string, r *Request, prepare func(*http.Request)) (*http.Response, error) {
+	req, err := newRequest(ctx, addr, r)
+	if err != nil {
+		return nil, err
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	return t.HTTPClient.Do(req)
+}
+
+// Do performs r and passes the body of a 200 response to decode, which may
+// be nil when only the status matters. Other statuses yield an *Error.
+func (t *Transport) Do(ctx context.Context, r *Request, decode func(io.Reader) error) error {
+	res, err := t.Send(ctx, r)
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	defer Close(res)
+
+	if res.StatusCode != http.StatusOK {
+		err := NewError(res)
+		log.WithField("request_id", err.RequestID).Error(err.Error())
+		return err
+	}
+
+	if decode == nil {
+		return nil
+	}
+	if err := decode(res.Body); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// DoCached is like Do for requests whose decoded value is kept in t.Cache.
+// When the server answers 304 Not Modified the cached value is returned
+// instead, so callers must not modify it.
+func (t *Transport) DoCached(ctx context.Context, r *Request, decode func(io.Reader) (interface{}, error)) (interface{}, error) {
+	res, err := t.send(ctx, r, t.Cache.Prepare)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	defer Close(res)
+
+	if res.StatusCode == http.StatusNotModified {
+		if v, ok := t.Cache.Lookup(res.Request); ok {
+			return v, nil
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		err := NewError(res)
+		log.WithField("request_id", err.RequestID).Error(err.Error())
+		return nil, err
+	}
+
+	v, err := decode(res.Body)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	t.Cache.Store(res, v)
+
+	return v, nil
+}
+
+func newRequest(ctx context.Context, addr string, r *Request) (*http.Request, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s", addr, r.Path))
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Query) > 0 {
+		q := u.Query()
+		for k, v := range r.Query {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequest(r.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	if r.Token != "" {
+		req.Header.Set("X-Auth-Token", r.Token)
+	}
+	if r.RequestID != "" {
+		req.Header.Set(RequestIDHeader, r.RequestID)
+	}
+	if r.IdempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, r.IdempotencyKey)
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+
+	return req, nil
+}
+
+type idempotencyKey struct{}
+
+// ContextWithIdempotencyKey pins the idempotency key of mutating requests
+// sent with the returned context, so a call retried by the caller is
+// recognised by the server. Each request gets key suffixed with a digest of
+// its method, path, query and body, which keeps the requests of calls made
+// of several of them apart while a repeated request gets the same key.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFor(ctx context.Context, r *Request) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	if key == "" {
+		return newID()
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.Path, r.Query.Encode())
+	h.Write(r.Body)
+	return key + "-" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// newID returns a random version 4 UUID.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Close drains what is left of the response body and closes it.
+func Close(res *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxDrain))
+	res.Body.Close()
+}
+
+// ReadAll adapts a decoder working on whole bodies for use with Do.
+func ReadAll(fn func([]byte) error) func(io.Reader) error {
+	return func(r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	}
+}
+
+// ReadAllValue is ReadAll for use with DoCached.
+func ReadAllValue(fn func([]byte) (interface{}, error)) func(io.Reader) (interface{}, error) {
+	return func(r io.Reader) (interface{}, error) {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return fn(b)
+	}
+}
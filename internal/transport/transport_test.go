@@ -0,0 +1,175 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/containers" {
+			t.Errorf("Incorrect path, got: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("cluster_name") != "default" {
+			t.Errorf("Incorrect query, got: %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			t.Errorf("Incorrect token, got: %q", r.Header.Get("X-Auth-Token"))
+		}
+		if r.Header.Get("Content-Type") != FormContentType {
+			t.Errorf("Incorrect content type, got: %q", r.Header.Get("Content-Type"))
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != "hostname=test-01" {
+			t.Errorf("Incorrect body, got: %q", string(b))
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	q := url.Values{}
+	q.Set("cluster_name", "default")
+
+	var got string
+	err := New(ts.Client(), ts.URL).Do(context.Background(), &Request{
+		Method:      http.MethodPost,
+		Path:        "api/v1/containers",
+		Query:       q,
+		Token:       "secret",
+		Body:        []byte("hostname=test-01"),
+		ContentType: FormContentType,
+	}, ReadAll(func(b []byte) error {
+		got = string(b)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if got != "ok" {
+		t.Errorf("Incorrect body passed to decode, got: %q", got)
+	}
+}
+
+func TestDoError(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"error": "container not found"}`, "container not found"},
+		{`{"error": {"message": "container not found"}}`, "container not found"},
+		{`{"message": "container not found"}`, "container not found"},
+		{"container not found\n", "container not found"},
+		{"", "Not Found"},
+	}
+
+	for _, tt := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(tt.body))
+		}))
+
+		err := New(ts.Client(), ts.URL).Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+		ts.Close()
+
+		apiErr, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("Expected *Error, got: %#v", err)
+		}
+		if apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("Incorrect status code, got: %d", apiErr.StatusCode)
+		}
+		if apiErr.Error() != tt.want {
+			t.Errorf("Incorrect message for %q, got: %q, want: %q.", tt.body, apiErr.Error(), tt.want)
+		}
+	}
+}
+
+func TestDoClosesBody(t *testing.T) {
+	body := &trackingBody{Reader: strings.NewReader(`{"data": "unread"}`)}
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Header: http.Header{}, Request: req}, nil
+	})}
+
+	err := New(httpClient, "http://pathfinder").Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if !body.closed {
+		t.Errorf("Response body was not closed")
+	}
+	if n, _ := body.Read(make([]byte, 1)); n != 0 {
+		t.Errorf("Response body was not drained")
+	}
+}
+
+func TestDoRequestHeaders(t *testing.T) {
+	var requestID, idempotencyKey string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = r.Header.Get(RequestIDHeader)
+		idempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer ts.Close()
+
+	tr := New(ts.Client(), ts.URL)
+	tr.Do(context.Background(), &Request{Method: http.MethodGet}, nil)
+	if requestID == "" || idempotencyKey != "" {
+		t.Errorf("Reads should only carry a request id, got: %q %q", requestID, idempotencyKey)
+	}
+
+	err := tr.Do(context.Background(), &Request{Method: http.MethodPost}, nil)
+	if requestID == "" || idempotencyKey == "" || requestID == idempotencyKey {
+		t.Errorf("Writes should carry a request id and an idempotency key, got: %q %q", requestID, idempotencyKey)
+	}
+	if apiErr, ok := err.(*Error); !ok || apiErr.RequestID != requestID {
+		t.Errorf("Error should carry the request id %q, got: %#v", requestID, err)
+	}
+}
+
+func TestDoPinnedIdempotencyKey(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+	}))
+	defer ts.Close()
+
+	tr := New(ts.Client(), ts.URL)
+	ctx := ContextWithIdempotencyKey(context.Background(), "provision-01")
+	tr.Do(ctx, &Request{Method: http.MethodPost, Path: "containers", Body: []byte("a")}, nil)
+	tr.Do(ctx, &Request{Method: http.MethodPost, Path: "containers", Body: []byte("a")}, nil)
+	tr.Do(ctx, &Request{Method: http.MethodPost, Path: "containers", Body: []byte("b")}, nil)
+
+	if !strings.HasPrefix(keys[0], "provision-01-") {
+		t.Errorf("Key should derive from the pinned one, got: %q", keys[0])
+	}
+	if keys[0] != keys[1] {
+		t.Errorf("Repeated requests should reuse the key, got: %q and %q", keys[0], keys[1])
+	}
+	if keys[0] == keys[2] {
+		t.Errorf("Distinct requests should get distinct keys, got: %q", keys[2])
+	}
+}
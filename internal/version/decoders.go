@@ -0,0 +1,47 @@
+package version
+
+import (
+	"io"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+// Sets maps major API versions to the decoder set a client uses for them.
+type Sets map[int]interface{}
+
+// ContainerListDecoder streams the items of a container list response to fn
+// and returns the envelope's api_version.
+type ContainerListDecoder func(r io.Reader, fn func(pfmodel.Container) error) (string, error)
+
+// Select returns the set of sets for the server's major version as last
+// observed, or the one for Supported when sets has none for it.
+func (t *Tracker) Select(sets Sets) interface{} {
+	if s, ok := sets[t.Major()]; ok {
+		return s
+	}
+	return sets[Supported]
+}
+
+// ObserveBody observes the api_version of the response body b.
+func (t *Tracker) ObserveBody(b []byte) error {
+	return t.Observe(Peek(b))
+}
+
+// ReadContainerList collects a streamed container list response. The
+// envelope's api_version is only known once read, so dec must be chosen
+// from the version observed on earlier responses.
+func (t *Tracker) ReadContainerList(r io.Reader, dec ContainerListDecoder) (*pfmodel.ContainerList, error) {
+	cl := pfmodel.ContainerList{}
+	apiVersion, err := dec(r, func(c pfmodel.Container) error {
+		cl = append(cl, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Observe(apiVersion); err != nil {
+		return nil, err
+	}
+
+	return &cl, nil
+}
@@ -0,0 +1,59 @@
+package version
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+func TestSelect(t *testing.T) {
+	sets := Sets{1: "v1", 2: "v2"}
+
+	tables := []struct {
+		server string
+		want   string
+	}{
+		{"", "v1"},
+		{"2.1", "v2"},
+		{"3.0", "v1"},
+	}
+
+	for _, table := range tables {
+		tracker := New(false)
+		tracker.Observe(table.server)
+		if got := tracker.Select(sets); got != table.want {
+			t.Errorf("Incorrect set selected for %q, got: %v, want: %s.", table.server, got, table.want)
+		}
+	}
+
+	var tracker *Tracker
+	if got := tracker.Select(sets); got != "v1" {
+		t.Errorf("Incorrect set selected without tracker, got: %v, want: %s.", got, "v1")
+	}
+}
+
+func TestReadContainerList(t *testing.T) {
+	dec := func(r io.Reader, fn func(pfmodel.Container) error) (string, error) {
+		fn(pfmodel.Container{Hostname: "test-01"})
+		fn(pfmodel.Container{Hostname: "test-02"})
+		return "2.0", nil
+	}
+
+	tracker := New(false)
+	cl, err := tracker.ReadContainerList(strings.NewReader(""), dec)
+	if err != nil {
+		t.Fatalf("Error when reading container list: %s", err.Error())
+	}
+	if len(*cl) != 2 || (*cl)[1].Hostname != "test-02" {
+		t.Errorf("Incorrect container list, got: %v", *cl)
+	}
+	if tracker.Server() != "2.0" {
+		t.Errorf("Envelope version not observed, got: %q, want: %q.", tracker.Server(), "2.0")
+	}
+
+	if _, err := New(true).ReadContainerList(strings.NewReader(""), dec); err == nil {
+		t.Errorf("Strict tracker should reject the incompatible envelope version")
+	}
+}
@@ -0,0 +1,125 @@
+// Package version keeps track of the API version Pathfinder server reports in
+// the api_version field of its response envelopes and checks it against the
+// major version these clients understand.
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Supported is the major API version the clients are written against.
+const Supported = 1
+
+// IncompatibleError is returned in strict mode when the server reports a
+// major version other than Supported.
+type IncompatibleError struct {
+	Server string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("pathfinder server API version %q is not compatible with supported major version %d", e.Server, Supported)
+}
+
+// Major parses the major component of versions such as "1", "1.2" or
+// "v1.2.3".
+func Major(v string) (int, error) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		v = v[:i]
+	}
+	major, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid API version %q", v)
+	}
+	return major, nil
+}
+
+// Peek returns the api_version of a response envelope, or an empty string
+// when b carries none.
+func Peek(b []byte) string {
+	var res struct {
+		ApiVersion string `json:"api_version"`
+	}
+	json.Unmarshal(b, &res)
+	return res.ApiVersion
+}
+
+// Tracker records the latest version reported by the server. A nil Tracker
+// accepts every version and records nothing.
+type Tracker struct {
+	// Strict makes Observe fail on incompatible versions instead of only
+	// logging a warning.
+	Strict bool
+
+	mu     sync.Mutex
+	server string
+	major  int
+	warned map[string]bool
+}
+
+func New(strict bool) *Tracker {
+	return &Tracker{Strict: strict}
+}
+
+// Observe records v as the server's version. Responses without a version are
+// ignored, as older servers do not always send one.
+func (t *Tracker) Observe(v string) error {
+	if t == nil || v == "" {
+		return nil
+	}
+
+	major, err := Major(v)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.server = v
+	t.major = major
+	if err == nil && major == Supported {
+		return nil
+	}
+	if t.Strict {
+		return &IncompatibleError{Server: v}
+	}
+	if !t.warned[v] {
+		if t.warned == nil {
+			t.warned = make(map[string]bool)
+		}
+		t.warned[v] = true
+		log.Warnf("Pathfinder server API version %s is not compatible with supported major version %d", v, Supported)
+	}
+	return nil
+}
+
+// Server returns the last version reported by the server, or an empty
+// string when none was seen yet.
+func (t *Tracker) Server() string {
+	if t == nil {
+		return ""
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.server
+}
+
+// Major returns the major version of the server as last observed, falling
+// back to Supported when it is unknown.
+func (t *Tracker) Major() int {
+	if t == nil {
+		return Supported
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.server == "" || t.major == 0 {
+		return Supported
+	}
+	return t.major
+}
@@ -0,0 +1,57 @@
+package version
+
+import (
+	"testing"
+)
+
+func TestMajor(t *testing.T) {
+	tables := []struct {
+		version string
+		major   int
+		valid   bool
+	}{
+		{"1", 1, true},
+		{"1.0", 1, true},
+		{"v2.3.1", 2, true},
+		{"", 0, false},
+		{"beta", 0, false},
+	}
+
+	for _, table := range tables {
+		major, err := Major(table.version)
+		if (err == nil) != table.valid || major != table.major {
+			t.Errorf("Incorrect major for %q, got: %d (%v), want: %d.", table.version, major, err, table.major)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := New(false)
+	if tracker.Server() != "" || tracker.Major() != Supported {
+		t.Errorf("Unexpected initial version, got: %q", tracker.Server())
+	}
+
+	if err := tracker.Observe("1.0"); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if err := tracker.Observe(""); err != nil || tracker.Server() != "1.0" {
+		t.Errorf("Responses without version should be ignored, got: %q", tracker.Server())
+	}
+	if err := tracker.Observe("2.0"); err != nil {
+		t.Errorf("Lenient tracker should only warn, got: %s", err.Error())
+	}
+	if tracker.Server() != "2.0" || tracker.Major() != 2 {
+		t.Errorf("Incorrect server version, got: %q (%d)", tracker.Server(), tracker.Major())
+	}
+
+	strict := New(true)
+	err := strict.Observe("2.0")
+	if _, ok := err.(*IncompatibleError); !ok {
+		t.Errorf("Expected *IncompatibleError, got: %#v", err)
+	}
+
+	var nilTracker *Tracker
+	if nilTracker.Observe("2.0") != nil || nilTracker.Server() != "" || nilTracker.Major() != Supported {
+		t.Errorf("Nil tracker should accept every version")
+	}
+}
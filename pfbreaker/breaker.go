@@ -0,0 +1,191 @@
+// Package pfbreaker stops clients from sending requests to a Pathfinder
+// server that keeps failing, so callers fail fast instead of each waiting
+// for the full HTTP timeout.
+package pfbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of sending a request while the breaker
+// is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Outcome is what a request allowed through the breaker reports back.
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Failure
+	// Ignored is for requests that ended without telling anything about the
+	// server, such as those cancelled by the caller.
+	Ignored
+)
+
+type Config struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// breaker, defaults to 5.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probe
+	// requests through, defaults to 30 seconds.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probe requests allowed at once
+	// while half-open, defaults to 1.
+	HalfOpenRequests int
+}
+
+type Status struct {
+	State               State     `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenedAt            time.Time `json:"opened_at"`
+}
+
+// Breaker is safe for concurrent use. A nil *Breaker lets every request
+// through.
+type Breaker struct {
+	cfg Config
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+func New(cfg Config) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &Breaker{cfg: cfg}
+}
+
+// Allow returns ErrCircuitOpen when the request must not be sent. Otherwise
+// report must be called exactly once with the request's outcome.
+func (b *Breaker) Allow() (report func(Outcome), err error) {
+	if b == nil {
+		return func(Outcome) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		b.state = HalfOpen
+		b.probes = 0
+	}
+
+	switch b.state {
+	case Open:
+		return nil, ErrCircuitOpen
+	case HalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+		return b.reporter(true), nil
+	}
+	return b.reporter(false), nil
+}
+
+func (b *Breaker) reporter(probe bool) func(Outcome) {
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { b.report(probe, outcome) })
+	}
+}
+
+func (b *Breaker) report(probe bool, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe && b.state == HalfOpen {
+		b.probes--
+	}
+
+	switch outcome {
+	case Success:
+		b.failures = 0
+		if b.state == HalfOpen {
+			b.state = Closed
+		}
+	case Failure:
+		b.failures++
+		if (probe && b.state == HalfOpen) || (b.state == Closed && b.failures >= b.cfg.FailureThreshold) {
+			b.state = Open
+			b.openedAt = time.Now()
+		}
+	}
+}
+
+// State returns the current state, moving from open to half-open once the
+// open timeout is over.
+func (b *Breaker) State() State {
+	return b.Status().State
+}
+
+func (b *Breaker) Status() Status {
+	if b == nil {
+		return Status{State: Closed}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.state
+	if state == Open && time.Since(b.openedAt) >= b.cfg.OpenTimeout {
+		state = HalfOpen
+	}
+	status := Status{State: state, ConsecutiveFailures: b.failures}
+	if state != Closed {
+		status.OpenedAt = b.openedAt
+	}
+	return status
+}
+
+// ServeHTTP writes the current status as JSON, with status 503 while open,
+// so it can be mounted on an agent's health endpoint.
+func (b *Breaker) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	status := b.Status()
+
+	res.Header().Set("Content-Type", "application/json")
+	if status.State == Open {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		res.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(res).Encode(status)
+}
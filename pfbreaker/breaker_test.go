@@ -0,0 +1,84 @@
+package pfbreaker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := New(Config{FailureThreshold: 2, OpenTimeout: 20 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		report, err := b.Allow()
+		if err != nil {
+			t.Fatalf("Closed breaker should allow requests, got: %s", err.Error())
+		}
+		report(Failure)
+	}
+	if b.State() != Open {
+		t.Errorf("Breaker should open after consecutive failures, got: %s", b.State())
+	}
+	if _, err := b.Allow(); err != ErrCircuitOpen {
+		t.Errorf("Open breaker should fail fast, got: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != HalfOpen {
+		t.Errorf("Breaker should be half-open after its timeout, got: %s", b.State())
+	}
+	report, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Half-open breaker should allow a probe, got: %s", err.Error())
+	}
+	if _, err := b.Allow(); err != ErrCircuitOpen {
+		t.Errorf("Half-open breaker should allow a single probe, got: %v", err)
+	}
+	report(Failure)
+	if b.State() != Open {
+		t.Errorf("Failed probe should reopen the breaker, got: %s", b.State())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	report, _ = b.Allow()
+	report(Success)
+	if b.State() != Closed {
+		t.Errorf("Successful probe should close the breaker, got: %s", b.State())
+	}
+}
+
+func TestBreakerIgnoredOutcome(t *testing.T) {
+	b := New(Config{FailureThreshold: 1})
+
+	report, _ := b.Allow()
+	report(Ignored)
+	report(Failure)
+	if b.State() != Closed {
+		t.Errorf("Ignored outcomes should not count and reports only count once, got: %s", b.State())
+	}
+
+	var nilBreaker *Breaker
+	if _, err := nilBreaker.Allow(); err != nil || nilBreaker.State() != Closed {
+		t.Errorf("Nil breaker should let every request through")
+	}
+}
+
+func TestBreakerServeHTTP(t *testing.T) {
+	b := New(Config{FailureThreshold: 1})
+
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Incorrect status while closed, got: %d", rec.Code)
+	}
+
+	report, _ := b.Allow()
+	report(Failure)
+
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Incorrect status while open, got: %d", rec.Code)
+	}
+}
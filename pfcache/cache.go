@@ -0,0 +1,121 @@
+// Package pfcache keeps decoded responses of Pathfinder list endpoints so
+// clients can revalidate them with conditional requests.
+package pfcache
+
+import (
+	"net/http"
+	"sync"
+)
+
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+type entry struct {
+	etag         string
+	lastModified string
+	value        interface{}
+}
+
+// Cache is safe for concurrent use and may be shared between clients. A nil
+// *Cache is valid and caches nothing.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	hits    uint64
+	misses  uint64
+}
+
+func New() *Cache {
+	return &Cache{
+		entries: make(map[string]entry),
+	}
+}
+
+// Prepare adds If-None-Match and If-Modified-Since headers to req when a
+// response for its URL is cached.
+func (c *Cache) Prepare(req *http.Request) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[req.URL.String()]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if e.etag != "" {
+		req.Header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		req.Header.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// Lookup returns the value cached for the URL of a request the server
+// answered with 304 Not Modified, and counts it as a hit.
+func (c *Cache) Lookup(req *http.Request) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[req.URL.String()]
+	if !ok {
+		return nil, false
+	}
+	c.hits++
+	return e.value, true
+}
+
+// Store remembers the decoded value of a full response and counts a miss.
+// Responses without ETag or Last-Modified are not cached.
+func (c *Cache) Store(res *http.Response, value interface{}) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.misses++
+	key := res.Request.URL.String()
+	e := entry{
+		etag:         res.Header.Get("ETag"),
+		lastModified: res.Header.Get("Last-Modified"),
+		value:        value,
+	}
+	if e.etag == "" && e.lastModified == "" {
+		delete(c.entries, key)
+		return
+	}
+	c.entries[key] = e
+}
+
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:    c.hits,
+		Misses:  c.misses,
+		Entries: len(c.entries),
+	}
+}
+
+func (c *Cache) Hits() uint64 {
+	return c.Stats().Hits
+}
+
+func (c *Cache) Misses() uint64 {
+	return c.Stats().Misses
+}
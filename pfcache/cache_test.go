@@ -0,0 +1,68 @@
+package pfcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := New()
+
+	req := httptest.NewRequest(http.MethodGet, "http://pathfinder/containers?cluster_name=default", nil)
+	cache.Prepare(req)
+	if req.Header.Get("If-None-Match") != "" {
+		t.Errorf("No conditional header expected before caching")
+	}
+
+	res := &http.Response{
+		Request: req,
+		Header: http.Header{
+			"Etag":          []string{`"v1"`},
+			"Last-Modified": []string{"Mon, 01 Jan 2018 00:00:00 GMT"},
+		},
+	}
+	cache.Store(res, "containers")
+
+	req = httptest.NewRequest(http.MethodGet, "http://pathfinder/containers?cluster_name=default", nil)
+	cache.Prepare(req)
+	if req.Header.Get("If-None-Match") != `"v1"` {
+		t.Errorf("Incorrect If-None-Match header, got: %q, want: %q.", req.Header.Get("If-None-Match"), `"v1"`)
+	}
+	if req.Header.Get("If-Modified-Since") != "Mon, 01 Jan 2018 00:00:00 GMT" {
+		t.Errorf("Incorrect If-Modified-Since header, got: %q", req.Header.Get("If-Modified-Since"))
+	}
+
+	value, ok := cache.Lookup(req)
+	if !ok || value.(string) != "containers" {
+		t.Errorf("Incorrect cached value, got: %v", value)
+	}
+
+	other := httptest.NewRequest(http.MethodGet, "http://pathfinder/containers?cluster_name=staging", nil)
+	if _, ok := cache.Lookup(other); ok {
+		t.Errorf("Cache should be keyed on the full URL")
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("Incorrect cache stats, got: %+v", stats)
+	}
+}
+
+func TestCacheWithoutValidators(t *testing.T) {
+	cache := New()
+
+	req := httptest.NewRequest(http.MethodGet, "http://pathfinder/nodes", nil)
+	cache.Store(&http.Response{Request: req, Header: http.Header{}}, "nodes")
+
+	if _, ok := cache.Lookup(req); ok {
+		t.Errorf("Responses without validators should not be cached")
+	}
+
+	var nilCache *Cache
+	nilCache.Prepare(req)
+	nilCache.Store(&http.Response{Request: req, Header: http.Header{}}, "nodes")
+	if nilCache.Hits() != 0 || nilCache.Misses() != 0 {
+		t.Errorf("Nil cache should not count anything")
+	}
+}
@@ -0,0 +1,46 @@
+package pfclient
+
+import (
+	"encoding/json"
+)
+
+type StatusUpdate struct {
+	Hostname string `json:"hostname"`
+	Status   string `json:"status"`
+}
+
+type StatusUpdateResult struct {
+	Hostname string
+	Status   string
+	Err      error
+}
+
+type BatchTransitionReq struct {
+	NodeHostname string         `json:"node_hostname"`
+	Items        []StatusUpdate `json:"items"`
+}
+
+type BatchTransitionRes struct {
+	ApiVersion string                 `json:"api_version"`
+	Data       BatchTransitionDataRes `json:"data"`
+}
+
+type BatchTransitionDataRes struct {
+	Items []BatchTransitionItemRes `json:"items"`
+}
+
+type BatchTransitionItemRes struct {
+	Hostname string `json:"hostname"`
+	Status   string `json:"status"`
+	Error    string `json:"error"`
+}
+
+func NewBatchTransitionFromByte(b []byte) (*BatchTransitionDataRes, error) {
+	var res BatchTransitionRes
+	err := json.Unmarshal(b, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
@@ -1,7 +1,10 @@
 package pfclient
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"reflect"
 	"testing"
 
@@ -208,3 +211,81 @@ func TestNewContainerListFromByte(t *testing.T) {
 		}
 	}
 }
+
+func containerListBody(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"api_version": "1.0", "data": {"items": [`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{
+			"hostname": "test-%04d",
+			"ipaddress": "10.0.%d.%d",
+			"node_hostname": "node-01",
+			"status": "SCHEDULED",
+			"source": {
+				"source_type":"image", "mode":"pull", "fingerprint":"", "alias":"16.04",
+				"remote": {"server":"https://cloud-images.ubuntu.com/releases", "protocol":"simplestream", "auth_type":"none", "certificate": "random"}
+			},
+			"bootstrappers": [{
+				"bootstrap_type":"chef-solo",
+				"bootstrap_cookbooks_url":"127.0.0.1",
+				"bootstrap_attributes":{"consul":{"hosts":["guro-consul-01"]},"run_list":["role[consul]"]}
+			}]
+		}`, i, i/256, i%256)
+	}
+	buf.WriteString(`]}}`)
+	return buf.Bytes()
+}
+
+func TestNewContainerListFromReader(t *testing.T) {
+	b := containerListBody(10)
+
+	expected, _ := NewContainerListFromByte(b)
+	cl, err := NewContainerListFromReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Error when decoding container list: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(expected, cl) {
+		t.Errorf("Streamed container list differs, got: %+v, want: %+v.", cl, expected)
+	}
+}
+
+func BenchmarkNewContainerListFromByte(b *testing.B) {
+	body := containerListBody(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _ := ioutil.ReadAll(bytes.NewReader(body))
+		if _, err := NewContainerListFromByte(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewContainerListFromReader(b *testing.B) {
+	body := containerListBody(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewContainerListFromReader(bytes.NewReader(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeContainerList(b *testing.B) {
+	body := containerListBody(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := DecodeContainerList(bytes.NewReader(body), func(c pfmodel.Container) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
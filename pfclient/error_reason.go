@@ -0,0 +1,38 @@
+package pfclient
+
+import (
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+type ErrorReasonReq struct {
+	Hostname string             `json:"hostname"`
+	Reason   ErrorReasonDataReq `json:"reason"`
+}
+
+type ErrorReasonDataReq struct {
+	Message   string `json:"message"`
+	ExitCode  int    `json:"exit_code"`
+	LogTail   string `json:"log_tail,omitempty"`
+	Phase     string `json:"phase"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// newErrorReasonReq caps the size of reason and defaults its phase to the
+// one of the failed transition.
+func newErrorReasonReq(hostname, phase string, reason pfmodel.ErrorReason) ErrorReasonReq {
+	reason = reason.Truncate()
+	if reason.Phase == "" {
+		reason.Phase = phase
+	}
+
+	return ErrorReasonReq{
+		Hostname: hostname,
+		Reason: ErrorReasonDataReq{
+			Message:   reason.Message,
+			ExitCode:  reason.ExitCode,
+			LogTail:   reason.LogTail,
+			Phase:     reason.Phase,
+			Truncated: reason.Truncated,
+		},
+	}
+}
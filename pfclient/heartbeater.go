@@ -0,0 +1,117 @@
+package pfclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type HeartbeatHealth struct {
+	Healthy             bool      `json:"healthy"`
+	LastAttemptAt       time.Time `json:"last_attempt_at"`
+	LastSuccessAt       time.Time `json:"last_success_at"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Heartbeater periodically tells Pathfinder server that a node is alive and
+// keeps track of how well that is going.
+type Heartbeater struct {
+	client           Pfclient
+	node             string
+	interval         time.Duration
+	failureThreshold int
+
+	mu     sync.RWMutex
+	health HeartbeatHealth
+}
+
+// defaultHeartbeatInterval is used by heartbeaters given no interval.
+const defaultHeartbeatInterval = 10 * time.Second
+
+// NewHeartbeater creates a heartbeater that reports itself unhealthy after
+// failureThreshold consecutive failed heartbeats. An interval that is not
+// positive defaults to 10 seconds.
+func NewHeartbeater(client Pfclient, node string, interval time.Duration, failureThreshold int) *Heartbeater {
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
+	return &Heartbeater{
+		client:           client,
+		node:             node,
+		interval:         interval,
+		failureThreshold: failureThreshold,
+	}
+}
+
+// Run sends a heartbeat every interval until ctx is done.
+func (h *Heartbeater) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.Beat(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Beat sends a single heartbeat and records its outcome.
+func (h *Heartbeater) Beat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+
+	_, err := h.client.Heartbeat(ctx, h.node)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.health.LastAttemptAt = time.Now()
+	if err != nil {
+		h.health.ConsecutiveFailures++
+		h.health.LastError = err.Error()
+		if h.health.ConsecutiveFailures == h.failureThreshold {
+			log.Warnf("Heartbeat for %s failed %d times in a row", h.node, h.health.ConsecutiveFailures)
+		}
+	} else {
+		h.health.ConsecutiveFailures = 0
+		h.health.LastError = ""
+		h.health.LastSuccessAt = h.health.LastAttemptAt
+	}
+	h.health.Healthy = !h.health.LastSuccessAt.IsZero() &&
+		h.health.ConsecutiveFailures < h.failureThreshold
+
+	return err
+}
+
+func (h *Heartbeater) Health() HeartbeatHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.health
+}
+
+// ServeHTTP writes the current health as JSON, with status 503 when
+// unhealthy, so it can be mounted directly on an agent's /healthz.
+func (h *Heartbeater) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	health := h.Health()
+
+	res.Header().Set("Content-Type", "application/json")
+	if health.Healthy {
+		res.WriteHeader(http.StatusOK)
+	} else {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(res).Encode(health)
+}
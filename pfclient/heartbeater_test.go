@@ -0,0 +1,90 @@
+package pfclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeater(t *testing.T) {
+	status := http.StatusOK
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(status)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	heartbeater := NewHeartbeater(pfclient, "test-01", time.Second, 2)
+
+	if heartbeater.Health().Healthy {
+		t.Errorf("Heartbeater should not be healthy before the first heartbeat")
+	}
+
+	heartbeater.Beat(context.Background())
+	if !heartbeater.Health().Healthy {
+		t.Errorf("Heartbeater should be healthy after a successful heartbeat")
+	}
+
+	status = http.StatusInternalServerError
+	heartbeater.Beat(context.Background())
+	if !heartbeater.Health().Healthy {
+		t.Errorf("Heartbeater should tolerate failures below the threshold")
+	}
+
+	heartbeater.Beat(context.Background())
+	health := heartbeater.Health()
+	if health.Healthy {
+		t.Errorf("Heartbeater should be unhealthy after reaching the failure threshold")
+	}
+	if health.ConsecutiveFailures != 2 {
+		t.Errorf("Incorrect consecutive failures, got: %d, want: %d.", health.ConsecutiveFailures, 2)
+	}
+
+	rec := httptest.NewRecorder()
+	heartbeater.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Incorrect health status code, got: %d, want: %d.", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHeartbeaterWhileRegistering(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "authentication_token": "123"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	heartbeater := NewHeartbeater(pfclient, "test-01", time.Millisecond, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		heartbeater.Run(ctx)
+		close(done)
+	}()
+
+	// Registering again swaps the token the heartbeater is sending
+	for i := 0; i < 10; i++ {
+		pfclient.Register("test-01", "127.0.0.1")
+		pfclient.Deregister(context.Background(), "test-01")
+	}
+	cancel()
+	<-done
+}
+
+func TestHeartbeaterDefaultInterval(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	heartbeater := NewHeartbeater(pfclient, "test-01", 0, 1)
+
+	if err := heartbeater.Beat(context.Background()); err != nil {
+		t.Errorf("Error when sending heartbeat without an interval: %s", err.Error())
+	}
+}
@@ -0,0 +1,112 @@
+package pfclient
+
+import (
+	"crypto/tls"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfcache"
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftls"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftrace"
+)
+
+type Option func(*pfclient)
+
+// WithCache revalidates fetched container lists with conditional requests
+// and reuses the cached lists when the server answers 304 Not Modified.
+func WithCache(cache *pfcache.Cache) Option {
+	return func(p *pfclient) {
+		p.tr.Cache = cache
+	}
+}
+
+// WithStrictAPIVersion makes requests fail with an error when the server
+// reports an API major version the client does not support, instead of
+// logging a warning and decoding the response anyway.
+func WithStrictAPIVersion() Option {
+	return func(p *pfclient) {
+		p.versions.Strict = true
+	}
+}
+
+// WithTLSConfig makes the client talk TLS with tlsConfig, usually built with
+// pftls.Load. It works on a copy of the HTTP client given to the
+// constructor, which is left untouched. When the HTTP client does not use
+// an *http.Transport, every request fails with pftls.ErrUnsupportedTransport.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(p *pfclient) {
+		httpClient, err := pftls.Apply(p.httpClient, tlsConfig)
+		if err != nil {
+			p.tr.Err = err
+			return
+		}
+		p.httpClient = httpClient
+		p.tr.HTTPClient = httpClient
+	}
+}
+
+// WithTokenStore makes Register reuse the token saved in store instead of
+// registering again on every start, and Deregister forget it.
+func WithTokenStore(store TokenStore) Option {
+	return func(p *pfclient) {
+		p.tokenStore = store
+	}
+}
+
+// WithClusterPasswordProvider reads the cluster password from provider when
+// registering instead of using the one given to the constructor. The
+// password is read again when the server rejects it.
+func WithClusterPasswordProvider(provider pfsecret.Provider) Option {
+	return func(p *pfclient) {
+		p.password = pfsecret.NewSource(provider)
+	}
+}
+
+// WithEndpoints spreads requests over several Pathfinder server replicas
+// instead of the single address given to the constructor. Requests stick to
+// the replica that last answered and fail over to the next one on
+// connection errors and 5xx responses.
+func WithEndpoints(addrs ...string) Option {
+	return func(p *pfclient) {
+		if len(addrs) == 0 {
+			return
+		}
+		p.pfServerAddr = addrs[0]
+		p.tr.SetEndpoints(addrs)
+	}
+}
+
+// WithHealthCheckPath makes replicas that failed be probed on path, e.g.
+// "healthz", before requests are sent to them again.
+func WithHealthCheckPath(path string) Option {
+	return func(p *pfclient) {
+		p.tr.HealthPath = path
+	}
+}
+
+// WithLimiter throttles requests with limiter, waiting for it before each
+// request until the request's context is done. A limiter shared between
+// clients throttles them together.
+func WithLimiter(limiter *pflimit.Limiter) Option {
+	return func(p *pfclient) {
+		p.tr.Limiter = limiter
+	}
+}
+
+// WithBreaker stops sending requests while breaker is open, failing them
+// with ErrCircuitOpen instead. Connection errors and 5xx responses count as
+// failures.
+func WithBreaker(breaker *pfbreaker.Breaker) Option {
+	return func(p *pfclient) {
+		p.tr.Breaker = breaker
+	}
+}
+
+// WithTracer creates a span named after the client method for every request
+// and sends it to the server in the traceparent and tracestate headers.
+func WithTracer(tracer pftrace.Tracer) Option {
+	return func(p *pfclient) {
+		p.tr.Tracer = tracer
+	}
+}
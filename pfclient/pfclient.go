@@ -1,15 +1,23 @@
 package pfclient
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/url"
-
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/transport"
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/version"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
 	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -20,22 +28,83 @@ type Pfclient interface {
 	UpdateIpaddress(node, hostname, ipaddress string) (bool, error)
 	MarkContainerAsProvisioned(node, hostname string) (bool, error)
 	MarkContainerAsProvisionError(node, hostname string) (bool, error)
+	MarkContainerAsProvisionErrorWithReason(node, hostname string, reason pfmodel.ErrorReason) (bool, error)
 	MarkContainerAsBootstrapStarted(node, hostname string) (bool, error)
 	MarkContainerAsRelocateStarted(node, hostname string) (bool, error)
 	MarkContainerAsRelocateError(node, hostname string) (bool, error)
+	MarkContainerAsRelocateErrorWithReason(node, hostname string, reason pfmodel.ErrorReason) (bool, error)
 	MarkContainerAsBootstrapped(node, hostname string) (bool, error)
 	MarkContainerAsBootstrapError(node, hostname string) (bool, error)
+	MarkContainerAsBootstrapErrorWithReason(node, hostname string, reason pfmodel.ErrorReason) (bool, error)
 	MarkContainerAsDeleted(node, hostname string) (bool, error)
 	StoreMetrics(collectedMetrics *pfmodel.Metrics) (bool, error)
+	Heartbeat(ctx context.Context, node string) (bool, error)
+	Deregister(ctx context.Context, node string) (bool, error)
+	Drain(ctx context.Context, node string, pollInterval time.Duration) (bool, error)
+	BatchTransition(ctx context.Context, node string, updates []StatusUpdate) ([]StatusUpdateResult, error)
+	WatchScheduledContainers(ctx context.Context, node string) (<-chan WatchEvent, error)
+	WithContext(ctx context.Context) Pfclient
+	ServerAPIVersion() string
+}
+
+// APIError is returned when Pathfinder server answers with an unexpected
+// status.
+type APIError = transport.Error
+
+// ErrCircuitOpen is returned without contacting the server while the
+// breaker given with WithBreaker is open.
+var ErrCircuitOpen = pfbreaker.ErrCircuitOpen
+
+// ContextWithIdempotencyKey pins the idempotency key of the writes sent with
+// the returned context, given to WithContext, so a MarkContainerAsProvisioned retried
+// with the same key and arguments is applied once by the server.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return transport.ContextWithIdempotencyKey(ctx, key)
+}
+
+const batchFallbackConcurrency = 8
+
+// Outcomes of a request to the batch endpoint.
+const (
+	batchApplied = iota
+	// batchMissing is reported when the server certainly lacks the endpoint.
+	batchMissing
+	// batchNotFound is a 404, either from a missing endpoint or about the
+	// node itself.
+	batchNotFound
+)
+
+// statusPaths maps container statuses to the pfApiPath keys of the
+// endpoints that transition a container into them.
+var statusPaths = map[string]string{
+	pfmodel.StatusProvisioned:      "MarkProvisioned",
+	pfmodel.StatusProvisionError:   "MarkProvisionError",
+	pfmodel.StatusBootstrapStarted: "MarkBootstrapStarted",
+	pfmodel.StatusBootstrapped:     "MarkBootstrapped",
+	pfmodel.StatusBootstrapError:   "MarkBootstrapError",
+	pfmodel.StatusRelocateStarted:  "MarkRelocateStarted",
+	pfmodel.StatusRelocateError:    "MarkRelocateError",
+	pfmodel.StatusDeleted:          "MarkDeleted",
 }
 
 type pfclient struct {
 	cluster         string
 	clusterPassword string
 	token           string
+	tokenMu         sync.RWMutex
 	httpClient      *http.Client
 	pfServerAddr    string
 	pfApiPath       map[string]string
+	tr              *transport.Transport
+	versions        *version.Tracker
+	tokenStore      TokenStore
+	password        *pfsecret.Source
+	ctx             context.Context
+	// root is the client a WithContext client derives from, which keeps
+	// the token and batch support they share.
+	root *pfclient
+
+	batchUnsupported int32
 }
 
 func NewPfclient(
@@ -43,259 +112,640 @@ func NewPfclient(
 	clusterPassword string,
 	httpClient *http.Client,
 	pfServerAddr string,
-	pfApiPath map[string]string) Pfclient {
+	pfApiPath map[string]string,
+	opts ...Option) Pfclient {
 
-	return &pfclient{
+	p := &pfclient{
 		cluster:         cluster,
 		clusterPassword: clusterPassword,
 		httpClient:      httpClient,
 		pfServerAddr:    pfServerAddr,
 		pfApiPath:       pfApiPath,
+		tr:              transport.New(httpClient, pfServerAddr),
+		versions:        version.New(false),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+
+	return p
 }
 
-func (p *pfclient) Register(node string, ipaddress string) (bool, error) {
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, p.pfApiPath["Register"])
-	u, err := url.Parse(addr)
+// Register authenticates node against the cluster. With a TokenStore, a
+// saved token is reused as long as the server still accepts it and the node
+// kept the ip address it was registered with. The token is checked by
+// listing the node's scheduled containers, an endpoint every agent uses.
+// With a tracer, all of its requests share one span.
+func (p *pfclient) Register(node string, ipaddress string) (ok bool, err error) {
+	ctx, end := p.transport().StartOperation(p.requestContext(), "Register")
+	defer func() { end(err) }()
+
+	if p.tokenStore == nil {
+		return p.register(ctx, node, ipaddress)
+	}
+
+	token, savedIpaddress, err := p.tokenStore.Load(p.cluster, node)
+	if err != nil {
+		log.Warnf("Unable to load saved token of %s: %s", node, err.Error())
+	}
+	if token != "" && savedIpaddress != ipaddress {
+		log.Infof("Ip address of %s changed from %s, registering again", node, savedIpaddress)
+	} else if token != "" {
+		p.setAuthToken(token)
+		_, err := fetchContainers(ctx, p, "FetchScheduledContainersFromServer", node, p.pfApiPath["ListScheduledContainers"])
+		if err == nil {
+			return true, nil
+		}
+		if !invalidToken(err) {
+			return false, err
+		}
+		log.Infof("Saved token of %s is no longer valid, registering again", node)
+	}
+
+	ok, err = p.register(ctx, node, ipaddress)
 	if err != nil {
-		log.Error(err.Error())
 		return false, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", p.cluster)
-	q.Set("node_hostname", node)
-	q.Set("node_ipaddress", ipaddress)
-	u.RawQuery = q.Encode()
+	if err := p.tokenStore.Save(p.cluster, node, p.authToken(), ipaddress); err != nil {
+		log.Warnf("Unable to save token of %s: %s", node, err.Error())
+	}
+	return ok, nil
+}
 
-	form := url.Values{}
-	form.Set("password", p.clusterPassword)
-	body := bytes.NewBufferString(form.Encode())
+// register sends the cluster password and keeps the token issued in return.
+// When the password comes from a provider and is rejected, it is read again
+// and the registration retried once if it was rotated.
+func (p *pfclient) register(ctx context.Context, node string, ipaddress string) (bool, error) {
+	ok, err := p.registerWithPassword(ctx, node, ipaddress)
+	if p.password == nil || !unauthorized(err) {
+		return ok, err
+	}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), body)
-	res, err := p.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
+	changed, refreshErr := p.password.Refresh(ctx)
+	if refreshErr != nil {
+		log.Error(refreshErr.Error())
 		return false, err
 	}
+	if !changed {
+		return false, err
+	}
+	return p.registerWithPassword(ctx, node, ipaddress)
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return false, errors.New(string(b))
+func (p *pfclient) registerWithPassword(ctx context.Context, node string, ipaddress string) (bool, error) {
+	password := p.clusterPassword
+	if p.password != nil {
+		var err error
+		password, err = p.password.Get(ctx)
+		if err != nil {
+			log.Error(err.Error())
+			return false, err
+		}
 	}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	register, err := NewRegisterFromByte(b)
+	q := p.query(node)
+	q.Set("node_ipaddress", ipaddress)
+
+	form := url.Values{}
+	form.Set("password", password)
+
+	var register *RegisterDataRes
+	err := p.transport().Do(ctx, &transport.Request{
+		Operation:   "Register",
+		Method:      http.MethodPost,
+		Path:        p.pfApiPath["Register"],
+		Query:       q,
+		Body:        []byte(form.Encode()),
+		ContentType: transport.FormContentType,
+	}, transport.ReadAll(func(b []byte) error {
+		dec, err := p.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		register, err = dec.register(b)
+		return err
+	}))
 	if err != nil {
-		log.Error(err.Error())
 		return false, err
 	}
 
-	p.token = register.AuthenticationToken
+	p.setAuthToken(register.AuthenticationToken)
 	return true, nil
 }
 
 func (p *pfclient) FetchScheduledContainersFromServer(node string) (*pfmodel.ContainerList, error) {
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, p.pfApiPath["ListScheduledContainers"])
-	u, err := url.Parse(addr)
+	return fetchContainers(p.requestContext(), p, "FetchScheduledContainersFromServer", node, p.pfApiPath["ListScheduledContainers"])
+}
+
+func (p *pfclient) FetchProvisionedContainersFromServer(node string) (*pfmodel.ContainerList, error) {
+	return fetchContainers(p.requestContext(), p, "FetchProvisionedContainersFromServer", node, p.pfApiPath["ListBootstrapScheduledContainers"])
+}
+
+func (p *pfclient) UpdateIpaddress(node string, hostname string, ipaddress string) (bool, error) {
+	q := p.query(node)
+	q.Set("hostname", hostname)
+
+	form := url.Values{}
+	form.Set("ipaddress", ipaddress)
+
+	err := p.transport().Do(p.requestContext(), &transport.Request{
+		Operation:   "UpdateIpaddress",
+		Method:      http.MethodPost,
+		Path:        p.pfApiPath["UpdateIpaddress"],
+		Query:       q,
+		Token:       p.authToken(),
+		Body:        []byte(form.Encode()),
+		ContentType: transport.FormContentType,
+	}, nil)
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+		return false, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", p.cluster)
-	q.Set("node_hostname", node)
-	u.RawQuery = q.Encode()
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", p.token)
-	res, err := p.httpClient.Do(req)
+	return true, nil
+}
+
+func (p *pfclient) MarkContainerAsProvisioned(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsProvisioned", node, hostname, p.pfApiPath["MarkProvisioned"])
+}
+
+func (p *pfclient) MarkContainerAsProvisionError(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsProvisionError", node, hostname, p.pfApiPath["MarkProvisionError"])
+}
+
+// MarkContainerAsProvisionErrorWithReason also tells the server why
+// provisioning failed. Oversized messages and logs are truncated.
+func (p *pfclient) MarkContainerAsProvisionErrorWithReason(node string, hostname string, reason pfmodel.ErrorReason) (bool, error) {
+	return markContainerWithReason(p.requestContext(), p, "MarkContainerAsProvisionError", node, hostname, p.pfApiPath["MarkProvisionError"], pfmodel.PhaseProvision, reason)
+}
+
+func (p *pfclient) MarkContainerAsBootstrapStarted(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsBootstrapStarted", node, hostname, p.pfApiPath["MarkBootstrapStarted"])
+}
+
+func (p *pfclient) MarkContainerAsRelocateStarted(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsRelocateStarted", node, hostname, p.pfApiPath["MarkRelocateStarted"])
+}
+
+func (p *pfclient) MarkContainerAsRelocateError(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsRelocateError", node, hostname, p.pfApiPath["MarkRelocateError"])
+}
+
+// MarkContainerAsRelocateErrorWithReason also tells the server why the
+// relocation failed. Oversized messages and logs are truncated.
+func (p *pfclient) MarkContainerAsRelocateErrorWithReason(node string, hostname string, reason pfmodel.ErrorReason) (bool, error) {
+	return markContainerWithReason(p.requestContext(), p, "MarkContainerAsRelocateError", node, hostname, p.pfApiPath["MarkRelocateError"], pfmodel.PhaseRelocate, reason)
+}
+
+func (p *pfclient) MarkContainerAsBootstrapped(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsBootstrapped", node, hostname, p.pfApiPath["MarkBootstrapped"])
+}
+
+func (p *pfclient) MarkContainerAsBootstrapError(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsBootstrapError", node, hostname, p.pfApiPath["MarkBootstrapError"])
+}
+
+// MarkContainerAsBootstrapErrorWithReason also tells the server why
+// bootstrapping failed. Oversized messages and logs are truncated.
+func (p *pfclient) MarkContainerAsBootstrapErrorWithReason(node string, hostname string, reason pfmodel.ErrorReason) (bool, error) {
+	return markContainerWithReason(p.requestContext(), p, "MarkContainerAsBootstrapError", node, hostname, p.pfApiPath["MarkBootstrapError"], pfmodel.PhaseBootstrap, reason)
+}
+
+func (p *pfclient) MarkContainerAsDeleted(node string, hostname string) (bool, error) {
+	return markContainer(p.requestContext(), p, "MarkContainerAsDeleted", node, hostname, p.pfApiPath["MarkDeleted"])
+}
+
+func (p *pfclient) StoreMetrics(metrics *pfmodel.Metrics) (bool, error) {
+	b, err := json.Marshal(metrics)
 	if err != nil {
 		log.Error(err.Error())
-		return nil, err
+		return false, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
-	}
+	q := url.Values{}
+	q.Set("cluster_name", p.cluster)
 
-	b, _ := ioutil.ReadAll(res.Body)
-	serverContainers, err := NewContainerListFromByte(b)
+	err = p.transport().Do(p.requestContext(), &transport.Request{
+		Operation:   "StoreMetrics",
+		Method:      http.MethodPost,
+		Path:        p.pfApiPath["StoreMetrics"],
+		Query:       q,
+		Token:       p.authToken(),
+		Body:        b,
+		ContentType: transport.JSONContentType,
+	}, nil)
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+		return false, err
 	}
 
-	return serverContainers, nil
+	return true, nil
 }
 
-func (p *pfclient) FetchProvisionedContainersFromServer(node string) (*pfmodel.ContainerList, error) {
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, p.pfApiPath["ListBootstrapScheduledContainers"])
-	u, err := url.Parse(addr)
+func (p *pfclient) Heartbeat(ctx context.Context, node string) (bool, error) {
+	return updateNodeStatus(ctx, p, "Heartbeat", node, p.pfApiPath["Heartbeat"])
+}
+
+func (p *pfclient) Deregister(ctx context.Context, node string) (bool, error) {
+	ok, err := updateNodeStatus(ctx, p, "Deregister", node, p.pfApiPath["Deregister"])
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+		return false, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", p.cluster)
-	q.Set("node_hostname", node)
-	u.RawQuery = q.Encode()
 
-	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Header.Set("X-Auth-Token", p.token)
-	res, err := p.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+	p.setAuthToken("")
+	if p.tokenStore != nil {
+		if err := p.tokenStore.Delete(p.cluster, node); err != nil {
+			log.Warnf("Unable to delete saved token of %s: %s", node, err.Error())
+		}
 	}
+	return ok, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return nil, errors.New(string(b))
+// defaultDrainPollInterval is used by Drain when given no poll interval.
+const defaultDrainPollInterval = 5 * time.Second
+
+// Drain asks the server to move every container away from node, waits until
+// the node has nothing left to provision or bootstrap, then deregisters it.
+// Progress is checked every pollInterval, or every 5 seconds when it is not
+// positive. With a tracer, all of its requests share one span.
+func (p *pfclient) Drain(ctx context.Context, node string, pollInterval time.Duration) (ok bool, err error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultDrainPollInterval
 	}
 
-	b, _ := ioutil.ReadAll(res.Body)
-	serverContainers, err := NewContainerListFromByte(b)
+	ctx, end := p.transport().StartOperation(ctx, "Drain")
+	defer func() { end(err) }()
+
+	_, err = updateNodeStatus(ctx, p, "Drain", node, p.pfApiPath["Drain"])
 	if err != nil {
-		log.Error(err.Error())
-		return nil, err
+		return false, err
 	}
 
-	return serverContainers, nil
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		drained, err := p.isDrained(ctx, node)
+		if err != nil {
+			log.Warnf("Unable to check drain progress of %s: %s", node, err.Error())
+		} else if drained {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return p.Deregister(ctx, node)
 }
 
-func (p *pfclient) UpdateIpaddress(node string, hostname string, ipaddress string) (bool, error) {
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, p.pfApiPath["UpdateIpaddress"])
-	u, err := url.Parse(addr)
+func (p *pfclient) isDrained(ctx context.Context, node string) (bool, error) {
+	scheduled, err := fetchContainers(ctx, p, "Drain", node, p.pfApiPath["ListScheduledContainers"])
 	if err != nil {
-		log.Error(err.Error())
 		return false, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", p.cluster)
-	q.Set("node_hostname", node)
-	q.Set("hostname", hostname)
-	u.RawQuery = q.Encode()
+	if len(*scheduled) > 0 {
+		return false, nil
+	}
 
-	form := url.Values{}
-	form.Set("ipaddress", ipaddress)
-	body := bytes.NewBufferString(form.Encode())
+	provisioned, err := fetchContainers(ctx, p, "Drain", node, p.pfApiPath["ListBootstrapScheduledContainers"])
+	if err != nil {
+		return false, err
+	}
+	return len(*provisioned) == 0, nil
+}
+
+// BatchTransition applies many status updates in a single request when the
+// server has a BatchUpdateStatus endpoint, falling back to concurrent
+// individual updates otherwise. The returned error summarizes failures.
+// With a tracer, all of its requests share one span.
+func (p *pfclient) BatchTransition(ctx context.Context, node string, updates []StatusUpdate) (results []StatusUpdateResult, err error) {
+	ctx, end := p.transport().StartOperation(ctx, "BatchTransition")
+	defer func() { end(err) }()
+
+	results = make([]StatusUpdateResult, len(updates))
+	for i, update := range updates {
+		results[i].Hostname = update.Hostname
+		results[i].Status = update.Status
+		if _, ok := statusPaths[update.Status]; !ok {
+			results[i].Err = fmt.Errorf("unsupported container status %q", update.Status)
+		}
+	}
+
+	outcome := batchMissing
+	if p.pfApiPath["BatchUpdateStatus"] != "" && atomic.LoadInt32(&p.shared().batchUnsupported) == 0 {
+		var err error
+		outcome, err = p.batchTransition(ctx, node, results)
+		if err != nil {
+			for i := range results {
+				if results[i].Err == nil {
+					results[i].Err = err
+				}
+			}
+		}
+		switch outcome {
+		case batchApplied:
+			return results, batchTransitionError(results)
+		case batchMissing:
+			p.disableBatch()
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, batchFallbackConcurrency)
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(r *StatusUpdateResult) {
+			defer func() { <-sem; wg.Done() }()
+			_, r.Err = markContainer(ctx, p, "BatchTransition", node, r.Hostname, p.pfApiPath[statusPaths[r.Status]])
+		}(&results[i])
+	}
+	wg.Wait()
+
+	// A 404 from the batch endpoint only means it is missing when the
+	// server knows the node, as shown by an individual update succeeding.
+	if outcome == batchNotFound {
+		for _, r := range results {
+			if r.Err == nil {
+				p.disableBatch()
+				break
+			}
+		}
+	}
+
+	return results, batchTransitionError(results)
+}
+
+func (p *pfclient) disableBatch() {
+	if atomic.CompareAndSwapInt32(&p.shared().batchUnsupported, 0, 1) {
+		log.Warn("Server does not support batch status updates, falling back to individual updates")
+	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), body)
-	req.Header.Set("X-Auth-Token", p.token)
-	res, err := p.httpClient.Do(req)
+// batchTransition sends the valid entries of results to the batch endpoint
+// and records per-container outcomes. Unless the batch was applied, the
+// caller falls back to individual updates.
+func (p *pfclient) batchTransition(ctx context.Context, node string, results []StatusUpdateResult) (int, error) {
+	batch := BatchTransitionReq{NodeHostname: node}
+	for _, r := range results {
+		if r.Err == nil {
+			batch.Items = append(batch.Items, StatusUpdate{Hostname: r.Hostname, Status: r.Status})
+		}
+	}
+	if len(batch.Items) == 0 {
+		return batchApplied, nil
+	}
+
+	b, err := json.Marshal(batch)
 	if err != nil {
 		log.Error(err.Error())
-		return false, err
+		return batchApplied, err
+	}
+
+	var batchRes *BatchTransitionDataRes
+	err = p.transport().Do(ctx, &transport.Request{
+		Operation:   "BatchTransition",
+		Method:      http.MethodPost,
+		Path:        p.pfApiPath["BatchUpdateStatus"],
+		Query:       p.query(node),
+		Token:       p.authToken(),
+		Body:        b,
+		ContentType: transport.JSONContentType,
+	}, transport.ReadAll(func(b []byte) error {
+		dec, err := p.decodersFor(b)
+		if err != nil {
+			return err
+		}
+		batchRes, err = dec.batch(b)
+		return err
+	}))
+	if apiErr, ok := err.(*APIError); ok {
+		switch apiErr.StatusCode {
+		case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return batchMissing, nil
+		case http.StatusNotFound:
+			return batchNotFound, nil
+		}
+	}
+	if err != nil {
+		return batchApplied, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return false, errors.New(string(b))
+	itemErrors := make(map[string]string, len(batchRes.Items))
+	for _, item := range batchRes.Items {
+		if item.Error != "" {
+			itemErrors[item.Hostname] = item.Error
+		}
+	}
+	for i := range results {
+		if msg, ok := itemErrors[results[i].Hostname]; ok && results[i].Err == nil {
+			results[i].Err = errors.New(msg)
+		}
 	}
 
-	return true, nil
+	return batchApplied, nil
 }
 
-func (p *pfclient) MarkContainerAsProvisioned(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkProvisioned"])
+func unauthorized(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == http.StatusUnauthorized
 }
 
-func (p *pfclient) MarkContainerAsProvisionError(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkProvisionError"])
+// invalidToken reports whether err means the server does not accept the
+// token, as opposed to the server being unreachable or misconfigured.
+func invalidToken(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	}
+	return false
 }
 
-func (p *pfclient) MarkContainerAsBootstrapStarted(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkBootstrapStarted"])
+func batchTransitionError(results []StatusUpdateResult) error {
+	var msgs []string
+	for _, r := range results {
+		if r.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", r.Hostname, r.Err.Error()))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d status updates failed: %s", len(msgs), len(results), strings.Join(msgs, "; "))
 }
 
-func (p *pfclient) MarkContainerAsRelocateStarted(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkRelocateStarted"])
+// String keeps the cluster password and token out of logs and %v output.
+func (p *pfclient) String() string {
+	return fmt.Sprintf("pfclient{cluster: %s, server: %s, password: %s, token: %s}",
+		p.cluster, p.pfServerAddr, pfsecret.Redacted, pfsecret.Redacted)
 }
 
-func (p *pfclient) MarkContainerAsRelocateError(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkRelocateError"])
+func (p *pfclient) GoString() string {
+	return p.String()
 }
 
-func (p *pfclient) MarkContainerAsBootstrapped(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkBootstrapped"])
+// authToken returns the token issued on registration. Register and
+// Deregister change it while heartbeaters and watchers may be reading it.
+func (p *pfclient) authToken() string {
+	s := p.shared()
+	s.tokenMu.RLock()
+	defer s.tokenMu.RUnlock()
+	return s.token
 }
 
-func (p *pfclient) MarkContainerAsBootstrapError(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkBootstrapError"])
+func (p *pfclient) setAuthToken(token string) {
+	s := p.shared()
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+	s.token = token
 }
 
-func (p *pfclient) MarkContainerAsDeleted(node string, hostname string) (bool, error) {
-	return updateContainerStatus(p, node, hostname, p.pfApiPath["MarkDeleted"])
+// shared returns the client holding the state shared with derived clients.
+func (p *pfclient) shared() *pfclient {
+	if p.root != nil {
+		return p.root
+	}
+	return p
 }
 
-func (p *pfclient) StoreMetrics(metrics *pfmodel.Metrics) (bool, error) {
-	// Setup address and query params
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, p.pfApiPath["StoreMetrics"])
-	u, err := url.Parse(addr)
-	if err != nil {
-		log.Error(err.Error())
-		return false, err
+// WithContext returns a client whose requests are sent with ctx, so they
+// can be cancelled while waiting on the server or on a limiter given with
+// WithLimiter. Methods taking a context use theirs instead. The returned
+// client shares the token of p.
+func (p *pfclient) WithContext(ctx context.Context) Pfclient {
+	return &pfclient{
+		cluster:         p.cluster,
+		clusterPassword: p.clusterPassword,
+		httpClient:      p.httpClient,
+		pfServerAddr:    p.pfServerAddr,
+		pfApiPath:       p.pfApiPath,
+		tr:              p.tr,
+		versions:        p.versions,
+		tokenStore:      p.tokenStore,
+		password:        p.password,
+		ctx:             ctx,
+		root:            p.shared(),
+	}
+}
+
+// requestContext returns the context given with WithContext, if any.
+func (p *pfclient) requestContext() context.Context {
+	if p.ctx != nil {
+		return p.ctx
 	}
-	q := u.Query()
+	return context.Background()
+}
+
+// transport returns the shared request plumbing, building a throwaway one
+// for clients that were not created through NewPfclient.
+func (p *pfclient) transport() *transport.Transport {
+	if p.tr != nil {
+		return p.tr
+	}
+	return transport.New(p.httpClient, p.pfServerAddr)
+}
+
+func (p *pfclient) query(node string) url.Values {
+	q := url.Values{}
 	q.Set("cluster_name", p.cluster)
-	u.RawQuery = q.Encode()
+	q.Set("node_hostname", node)
+	return q
+}
 
-	// Setup request body
-	b, err := json.Marshal(metrics)
+func fetchContainers(ctx context.Context, p *pfclient, operation, node, path string) (*pfmodel.ContainerList, error) {
+	v, err := p.transport().DoCached(ctx, &transport.Request{
+		Operation: operation,
+		Method:    http.MethodGet,
+		Path:      path,
+		Query:     p.query(node),
+		Token:     p.authToken(),
+	}, func(r io.Reader) (interface{}, error) {
+		cl, err := p.readContainerList(r)
+		if err != nil {
+			return nil, err
+		}
+		return *cl, nil
+	})
 	if err != nil {
-		log.Error(err.Error())
-		return false, err
+		return nil, err
 	}
 
-	// Create the request and execute it
-	req, _ := http.NewRequest(http.MethodPost, u.String(), bytes.NewBuffer(b))
-	req.Header.Set("X-Auth-Token", p.token)
-	req.Header.Set("Content-Type", "application/json")
-	res, err := p.httpClient.Do(req)
+	// The list may be shared with the cache, hand out a copy
+	cl := v.(pfmodel.ContainerList).Copy()
+	return &cl, nil
+}
+
+func updateNodeStatus(ctx context.Context, p *pfclient, operation, node, status string) (bool, error) {
+	err := p.transport().Do(ctx, &transport.Request{
+		Operation: operation,
+		Method:    http.MethodPost,
+		Path:      status,
+		Query:     p.query(node),
+		Token:     p.authToken(),
+	}, nil)
 	if err != nil {
-		log.Error(err.Error())
 		return false, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return false, errors.New(string(b))
-	}
-
 	return true, nil
 }
 
 func updateContainerStatus(p *pfclient, node, hostname, status string) (bool, error) {
-	addr := fmt.Sprintf("%s/%s", p.pfServerAddr, status)
-	u, err := url.Parse(addr)
+	return markContainer(p.requestContext(), p, "UpdateContainerStatus", node, hostname, status)
+}
+
+func markContainer(ctx context.Context, p *pfclient, operation, node, hostname, status string) (bool, error) {
+	return sendMark(ctx, p, newMarkRequest(p, operation, node, hostname, status))
+}
+
+// markContainerWithReason sends reason as a JSON body. The hostname stays in
+// the query so servers unaware of reasons still apply the transition.
+func markContainerWithReason(ctx context.Context, p *pfclient, operation, node, hostname, status, phase string, reason pfmodel.ErrorReason) (bool, error) {
+	b, err := json.Marshal(newErrorReasonReq(hostname, phase, reason))
 	if err != nil {
 		log.Error(err.Error())
 		return false, err
 	}
-	q := u.Query()
-	q.Set("cluster_name", p.cluster)
-	q.Set("node_hostname", node)
+
+	r := newMarkRequest(p, operation, node, hostname, status)
+	r.Body = b
+	r.ContentType = transport.JSONContentType
+	return sendMark(ctx, p, r)
+}
+
+func newMarkRequest(p *pfclient, operation, node, hostname, status string) *transport.Request {
+	q := p.query(node)
 	q.Set("hostname", hostname)
-	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
-	req.Header.Set("X-Auth-Token", p.token)
-	res, err := p.httpClient.Do(req)
-	if err != nil {
-		log.Error(err.Error())
-		return false, err
+	return &transport.Request{
+		Operation: operation,
+		Method:    http.MethodPost,
+		Path:      status,
+		Query:     q,
+		Token:     p.authToken(),
 	}
+}
 
-	if res.StatusCode != http.StatusOK {
-		b, _ := ioutil.ReadAll(res.Body)
-		log.Error(string(b))
-		return false, errors.New(string(b))
+func sendMark(ctx context.Context, p *pfclient, r *transport.Request) (bool, error) {
+	err := p.transport().Do(ctx, r, nil)
+	if err != nil {
+		return false, err
 	}
 
 	return true, nil
@@ -1,11 +1,26 @@
 package pfclient
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/pathfinder-cm/pathfinder-go-client/pfbreaker"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfcache"
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
 	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfsecret"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftls"
+	"github.com/pathfinder-cm/pathfinder-go-client/pftrace"
 )
 
 func TestRegister(t *testing.T) {
@@ -37,6 +52,197 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegisterAPIVersion(t *testing.T) {
+	b := []byte(`{
+		"api_version": "2.0",
+		"data": {
+			"hostname": "test-01",
+			"authentication_token": "123"
+		}
+	}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	if pfclient.ServerAPIVersion() != "" {
+		t.Errorf("Unexpected server API version before any request, got: %s", pfclient.ServerAPIVersion())
+	}
+	ok, err := pfclient.Register("test-01", "127.0.0.1")
+	if !ok || err != nil {
+		t.Errorf("Incompatible versions should only warn by default, got: %v", err)
+	}
+	if pfclient.ServerAPIVersion() != "2.0" {
+		t.Errorf("Incorrect server API version, got: %s, want: %s.", pfclient.ServerAPIVersion(), "2.0")
+	}
+
+	strict := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{}, WithStrictAPIVersion())
+	ok, err = strict.Register("test-01", "127.0.0.1")
+	if ok || err == nil {
+		t.Errorf("Strict client should reject incompatible versions")
+	}
+}
+
+func TestRegisterWithTLSConfig(t *testing.T) {
+	testServer := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "authentication_token": "123"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(testServer.Certificate())
+
+	httpClient := &http.Client{}
+	pfclient := NewPfclient("default", "", httpClient, testServer.URL, map[string]string{},
+		WithTLSConfig(&tls.Config{RootCAs: pool}))
+	ok, err := pfclient.Register("test-01", "127.0.0.1")
+	if !ok || err != nil {
+		t.Errorf("Registration over TLS unsuccessful: %v", err)
+	}
+	if httpClient.Transport != nil {
+		t.Errorf("HTTP client given to the constructor should not be modified")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRegisterWithTLSConfigUnsupportedTransport(t *testing.T) {
+	called := false
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		called = true
+	}))
+	defer func() { testServer.Close() }()
+
+	// A wrapping RoundTripper hides the TLS settings of its transport
+	httpClient := &http.Client{Transport: roundTripFunc(http.DefaultTransport.RoundTrip)}
+	pfclient := NewPfclient("default", "", httpClient, testServer.URL, map[string]string{},
+		WithTLSConfig(&tls.Config{}))
+	ok, err := pfclient.Register("test-01", "127.0.0.1")
+	if ok || err != pftls.ErrUnsupportedTransport {
+		t.Errorf("Incorrect registration result, got: %v %v, want: %v.", ok, err, pftls.ErrUnsupportedTransport)
+	}
+	if called {
+		t.Errorf("Server should not be contacted without the requested TLS settings")
+	}
+}
+
+func TestRegisterWithTokenStore(t *testing.T) {
+	registrations := 0
+	var registeredIpaddress string
+	validToken := "123"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/register":
+			registrations++
+			registeredIpaddress = req.URL.Query().Get("node_ipaddress")
+			res.WriteHeader(http.StatusOK)
+			res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "authentication_token": "` + validToken + `"}}`))
+		case "/scheduled":
+			if req.Header.Get("X-Auth-Token") != validToken {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			res.WriteHeader(http.StatusOK)
+			res.Write([]byte(`{"api_version": "1.0", "data": {"items": []}}`))
+		case "/deregister":
+			res.WriteHeader(http.StatusOK)
+		default:
+			// No heartbeat endpoint on this server
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer func() { testServer.Close() }()
+
+	store := NewMemoryTokenStore()
+	apiPath := map[string]string{"Register": "register", "ListScheduledContainers": "scheduled", "Deregister": "deregister"}
+
+	for i := 0; i < 2; i++ {
+		pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, apiPath, WithTokenStore(store))
+		ok, err := pfclient.Register("test-01", "127.0.0.1")
+		if !ok || err != nil {
+			t.Fatalf("Registration unsuccessful: %v", err)
+		}
+	}
+	if registrations != 1 {
+		t.Errorf("Saved token should be reused, got %d registrations", registrations)
+	}
+
+	// The server forgets the token
+	validToken = "456"
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, apiPath, WithTokenStore(store))
+	pfclient.Register("test-01", "127.0.0.1")
+	if registrations != 2 {
+		t.Errorf("Invalid token should trigger a registration, got %d registrations", registrations)
+	}
+	if token, _, _ := store.Load("default", "test-01"); token != "456" {
+		t.Errorf("Incorrect saved token, got: %q, want: %q.", token, "456")
+	}
+
+	// The node comes back with another address
+	pfclient = NewPfclient("default", "", &http.Client{}, testServer.URL, apiPath, WithTokenStore(store))
+	pfclient.Register("test-01", "127.0.0.2")
+	if registrations != 3 {
+		t.Errorf("Changed ip address should trigger a registration, got %d registrations", registrations)
+	}
+	if registeredIpaddress != "127.0.0.2" {
+		t.Errorf("Incorrect ip address registered, got: %q, want: %q.", registeredIpaddress, "127.0.0.2")
+	}
+	if _, ipaddress, _ := store.Load("default", "test-01"); ipaddress != "127.0.0.2" {
+		t.Errorf("Incorrect saved ip address, got: %q, want: %q.", ipaddress, "127.0.0.2")
+	}
+
+	pfclient.Deregister(context.Background(), "test-01")
+	if token, _, _ := store.Load("default", "test-01"); token != "" {
+		t.Errorf("Saved token should be deleted on deregistration, got: %q", token)
+	}
+}
+
+func TestRegisterWithClusterPasswordProvider(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		if req.PostForm.Get("password") != "rotated-password" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "authentication_token": "123"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	reads := 0
+	password := pfsecret.ProviderFunc(func(context.Context) (string, error) {
+		reads++
+		if reads == 1 {
+			return "old-password", nil
+		}
+		return "rotated-password", nil
+	})
+	pfclient := NewPfclient("default", "plain-password", &http.Client{}, testServer.URL, map[string]string{},
+		WithClusterPasswordProvider(password))
+	if reads != 0 {
+		t.Errorf("Password should be read lazily")
+	}
+
+	ok, err := pfclient.Register("test-01", "127.0.0.1")
+	if !ok || err != nil {
+		t.Errorf("Rotated password should be picked up after a 401, got: %v", err)
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		if out := fmt.Sprintf(format, pfclient); strings.Contains(out, "plain-password") || strings.Contains(out, "123") {
+			t.Errorf("Secret leaked with %s, got: %s", format, out)
+		}
+	}
+}
+
 func TestFetchScheduledContainersFromServer(t *testing.T) {
 	node := "test-01"
 	bootstrappers := []pfmodel.Bootstrapper{
@@ -608,6 +814,61 @@ func TestMarkContainerAsBootstrapError(t *testing.T) {
 	}
 }
 
+func TestMarkContainerAsErrorWithReason(t *testing.T) {
+	tables := []struct {
+		mark  func(Pfclient, string, string, pfmodel.ErrorReason) (bool, error)
+		path  string
+		phase string
+	}{
+		{Pfclient.MarkContainerAsProvisionErrorWithReason, "/provision_error", pfmodel.PhaseProvision},
+		{Pfclient.MarkContainerAsBootstrapErrorWithReason, "/bootstrap_error", pfmodel.PhaseBootstrap},
+		{Pfclient.MarkContainerAsRelocateErrorWithReason, "/relocate_error", pfmodel.PhaseRelocate},
+	}
+
+	var path, hostname string
+	var req ErrorReasonReq
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		hostname = r.URL.Query().Get("hostname")
+		req = ErrorReasonReq{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Error when decoding reason: %s", err.Error())
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"MarkProvisionError": "provision_error",
+		"MarkBootstrapError": "bootstrap_error",
+		"MarkRelocateError":  "relocate_error",
+	})
+	reason := pfmodel.ErrorReason{
+		Message:  "chef run failed",
+		ExitCode: 1,
+		LogTail:  strings.Repeat("x", pfmodel.MaxErrorLogTailSize+1),
+	}
+
+	for _, table := range tables {
+		ok, err := table.mark(pfclient, "test-01", "test-c-01", reason)
+		if !ok || err != nil {
+			t.Fatalf("Error when marking container with reason: %v", err)
+		}
+		if path != table.path || hostname != "test-c-01" {
+			t.Errorf("Incorrect request, got: %s?hostname=%s, want: %s?hostname=%s.", path, hostname, table.path, "test-c-01")
+		}
+		if req.Hostname != "test-c-01" || req.Reason.Message != reason.Message || req.Reason.ExitCode != reason.ExitCode {
+			t.Errorf("Incorrect reason sent, got: %+v", req.Reason)
+		}
+		if req.Reason.Phase != table.phase {
+			t.Errorf("Incorrect reason phase, got: %s, want: %s.", req.Reason.Phase, table.phase)
+		}
+		if !req.Reason.Truncated || len(req.Reason.LogTail) > pfmodel.MaxErrorLogTailSize {
+			t.Errorf("Log tail should be truncated, got %d bytes", len(req.Reason.LogTail))
+		}
+	}
+}
+
 func TestMarkContainerAsDeleted(t *testing.T) {
 	tables := []struct {
 		node     string
@@ -680,3 +941,498 @@ func TestUpdateContainerStatus(t *testing.T) {
 		t.Errorf("Error when updating container status")
 	}
 }
+
+func TestHeartbeat(t *testing.T) {
+	var calledPath, token string
+	expectedPath := "heartbeat"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		token = req.Header.Get("X-Auth-Token")
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := pfclient{
+		cluster:      "default",
+		token:        "123",
+		httpClient:   &http.Client{},
+		pfServerAddr: testServer.URL,
+		pfApiPath:    map[string]string{"Heartbeat": expectedPath},
+	}
+	ok, _ := pfclient.Heartbeat(context.Background(), "test-01")
+	if ok != true {
+		t.Errorf("Error when sending heartbeat")
+	}
+
+	if "/"+expectedPath != calledPath {
+		t.Errorf("Mismatch path called, want: %q, got: %q", expectedPath, calledPath)
+	}
+
+	if token != "123" {
+		t.Errorf("Incorrect auth token sent, want: %q, got: %q", "123", token)
+	}
+}
+
+func TestHeartbeatWithLimiter(t *testing.T) {
+	calls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	limiter := pflimit.New(pflimit.Config{}, pflimit.Config{Rate: 1, Burst: 1})
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{"Heartbeat": "heartbeat"},
+		WithLimiter(limiter))
+
+	if ok, err := pfclient.Heartbeat(context.Background(), "test-01"); !ok || err != nil {
+		t.Fatalf("Heartbeat unsuccessful: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pfclient.Heartbeat(ctx, "test-01"); err != context.DeadlineExceeded {
+		t.Errorf("Throttled heartbeat should give up with its context, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Throttled heartbeat should not reach the server, got %d calls", calls)
+	}
+}
+
+func TestMarkContainerWithContext(t *testing.T) {
+	calls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	limiter := pflimit.New(pflimit.Config{}, pflimit.Config{Rate: 1, Burst: 1})
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{},
+		WithLimiter(limiter))
+
+	if ok, err := pfclient.MarkContainerAsProvisioned("test-01", "test-c-01"); !ok || err != nil {
+		t.Fatalf("Mark container unsuccessful: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pfclient.WithContext(ctx).MarkContainerAsProvisioned("test-01", "test-c-01"); err != context.DeadlineExceeded {
+		t.Errorf("Throttled call should give up with its context, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Throttled call should not reach the server, got %d calls", calls)
+	}
+}
+
+func TestStoreMetricsWithBreaker(t *testing.T) {
+	calls := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer func() { testServer.Close() }()
+
+	breaker := pfbreaker.New(pfbreaker.Config{FailureThreshold: 2, OpenTimeout: time.Minute})
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{},
+		WithBreaker(breaker))
+
+	for i := 0; i < 2; i++ {
+		if _, err := pfclient.StoreMetrics(&pfmodel.Metrics{}); err == nil {
+			t.Errorf("Expected error from failing server")
+		}
+	}
+	if _, err := pfclient.StoreMetrics(&pfmodel.Metrics{}); err != ErrCircuitOpen {
+		t.Errorf("Expected ErrCircuitOpen, got: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Open breaker should not reach the server, got %d calls", calls)
+	}
+}
+
+func TestHeartbeatWithTracer(t *testing.T) {
+	var traceparent string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	var spans []pftrace.SpanData
+	tracer := pftrace.NewTracer(pftrace.ExporterFunc(func(data pftrace.SpanData) {
+		spans = append(spans, data)
+	}))
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{"Heartbeat": "heartbeat"},
+		WithTracer(tracer))
+
+	parent, _ := pftrace.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	pfclient.Heartbeat(pftrace.ContextWithSpanContext(context.Background(), parent), "test-01")
+
+	if len(spans) != 1 || spans[0].Operation != "Heartbeat" {
+		t.Fatalf("Expected a Heartbeat span, got: %+v", spans)
+	}
+	if spans[0].Context.TraceID != parent.TraceID {
+		t.Errorf("Span should continue the caller's trace")
+	}
+	if traceparent != spans[0].Context.TraceParent() {
+		t.Errorf("Incorrect traceparent sent, got: %q, want: %q.", traceparent, spans[0].Context.TraceParent())
+	}
+	if spans[0].Attributes["http.status_code"] != http.StatusOK {
+		t.Errorf("Incorrect status code recorded, got: %v", spans[0].Attributes["http.status_code"])
+	}
+}
+
+func TestRegisterWithTracer(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		if req.PostForm.Get("password") != "rotated-password" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"hostname": "test-01", "authentication_token": "123"}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	var spans []pftrace.SpanData
+	tracer := pftrace.NewTracer(pftrace.ExporterFunc(func(data pftrace.SpanData) {
+		spans = append(spans, data)
+	}))
+	password := "old-password"
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{"Register": "register"},
+		WithTracer(tracer),
+		WithClusterPasswordProvider(pfsecret.ProviderFunc(func(context.Context) (string, error) {
+			current := password
+			password = "rotated-password"
+			return current, nil
+		})))
+
+	if ok, err := pfclient.Register("test-01", "127.0.0.1"); !ok || err != nil {
+		t.Fatalf("Register unsuccessful: %v", err)
+	}
+
+	if len(spans) != 3 {
+		t.Fatalf("Incorrect number of spans, got: %d, want: %d.", len(spans), 3)
+	}
+	operation := spans[2]
+	if operation.Operation != "Register" || operation.Err != nil {
+		t.Errorf("Expected a successful Register span, got: %+v", operation)
+	}
+	for _, span := range spans[:2] {
+		if span.Context.TraceID != operation.Context.TraceID || span.Parent != operation.Context.SpanID {
+			t.Errorf("Register attempts should be children of the Register span, got: %+v", span)
+		}
+		if span.Operation != "POST register" {
+			t.Errorf("Incorrect attempt span name, got: %s, want: %s.", span.Operation, "POST register")
+		}
+	}
+}
+
+func TestDeregister(t *testing.T) {
+	var calledPath string
+	expectedPath := "deregister"
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPath = req.URL.Path
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := pfclient{
+		cluster:      "default",
+		token:        "123",
+		httpClient:   &http.Client{},
+		pfServerAddr: testServer.URL,
+		pfApiPath:    map[string]string{"Deregister": expectedPath},
+	}
+	ok, _ := pfclient.Deregister(context.Background(), "test-01")
+	if ok != true {
+		t.Errorf("Error when deregistering node")
+	}
+
+	if "/"+expectedPath != calledPath {
+		t.Errorf("Mismatch path called, want: %q, got: %q", expectedPath, calledPath)
+	}
+
+	if pfclient.token != "" {
+		t.Errorf("Auth token should be cleared after deregistration, got: %q", pfclient.token)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	remaining := []byte(`{"api_version": "1.0", "data": {"items": [{"hostname": "test-c-01", "status": "SCHEDULED"}]}}`)
+	empty := []byte(`{"api_version": "1.0", "data": {"items": []}}`)
+
+	var calledPaths []string
+	scheduledFetches := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPaths = append(calledPaths, req.URL.Path)
+		res.WriteHeader(http.StatusOK)
+		switch req.URL.Path {
+		case "/scheduled":
+			scheduledFetches++
+			if scheduledFetches == 1 {
+				res.Write(remaining)
+			} else {
+				res.Write(empty)
+			}
+		case "/provisioned":
+			res.Write(empty)
+		}
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"Drain":                            "drain",
+		"Deregister":                       "deregister",
+		"ListScheduledContainers":          "scheduled",
+		"ListBootstrapScheduledContainers": "provisioned",
+	})
+	ok, err := pfclient.Drain(context.Background(), "test-01", time.Millisecond)
+	if ok != true {
+		t.Errorf("Error when draining node: %v", err)
+	}
+
+	expectedPaths := []string{"/drain", "/scheduled", "/scheduled", "/provisioned", "/deregister"}
+	if !reflect.DeepEqual(expectedPaths, calledPaths) {
+		t.Errorf("Mismatch paths called, want: %v, got: %v", expectedPaths, calledPaths)
+	}
+}
+
+func TestDrainCancelled(t *testing.T) {
+	remaining := []byte(`{"api_version": "1.0", "data": {"items": [{"hostname": "test-c-01", "status": "SCHEDULED"}]}}`)
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write(remaining)
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	ok, err := pfclient.Drain(ctx, "test-01", time.Millisecond)
+	if ok || err != context.DeadlineExceeded {
+		t.Errorf("Drain should stop when the context expires, got: %t, %v", ok, err)
+	}
+}
+
+func TestDrainDefaultPollInterval(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"api_version": "1.0", "data": {"items": []}}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	ok, err := pfclient.Drain(context.Background(), "test-01", 0)
+	if !ok || err != nil {
+		t.Errorf("Error when draining node without a poll interval: %v", err)
+	}
+}
+
+func TestBatchTransition(t *testing.T) {
+	updates := []StatusUpdate{
+		{"test-c-01", pfmodel.StatusProvisioned},
+		{"test-c-02", pfmodel.StatusProvisioned},
+		{"test-c-03", pfmodel.StatusBootstrapError},
+		{"test-c-04", "UNKNOWN"},
+	}
+
+	var gotBody BatchTransitionReq
+	var calledPaths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calledPaths = append(calledPaths, req.URL.Path)
+		json.NewDecoder(req.Body).Decode(&gotBody)
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{
+			"api_version": "1.0",
+			"data": {
+				"items": [
+					{"hostname": "test-c-01", "status": "PROVISIONED"},
+					{"hostname": "test-c-02", "status": "SCHEDULED", "error": "invalid transition"},
+					{"hostname": "test-c-03", "status": "BOOTSTRAP_ERROR"}
+				]
+			}
+		}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{"BatchUpdateStatus": "batch"})
+	results, err := pfclient.BatchTransition(context.Background(), "test-01", updates)
+	if err == nil {
+		t.Errorf("Batch with failed updates should return an error")
+	}
+
+	expectedPaths := []string{"/batch"}
+	if !reflect.DeepEqual(expectedPaths, calledPaths) {
+		t.Errorf("Mismatch paths called, want: %v, got: %v", expectedPaths, calledPaths)
+	}
+
+	if len(gotBody.Items) != 3 || gotBody.NodeHostname != "test-01" {
+		t.Errorf("Incorrect batch body sent, got: %+v", gotBody)
+	}
+
+	failed := map[string]bool{"test-c-02": true, "test-c-04": true}
+	for _, result := range results {
+		if (result.Err != nil) != failed[result.Hostname] {
+			t.Errorf("Incorrect outcome for %s, got error: %v", result.Hostname, result.Err)
+		}
+	}
+}
+
+func TestBatchTransitionFallback(t *testing.T) {
+	updates := []StatusUpdate{
+		{"test-c-01", pfmodel.StatusProvisioned},
+		{"test-c-02", pfmodel.StatusBootstrapped},
+	}
+
+	var mu sync.Mutex
+	calledPaths := map[string]int{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		calledPaths[req.URL.Path]++
+		mu.Unlock()
+
+		if req.URL.Path == "/batch" {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"BatchUpdateStatus": "batch",
+		"MarkProvisioned":   "provisioned",
+		"MarkBootstrapped":  "bootstrapped",
+	})
+	for i := 0; i < 2; i++ {
+		results, err := pfclient.BatchTransition(context.Background(), "test-01", updates)
+		if err != nil {
+			t.Errorf("Error when falling back to individual updates: %s", err.Error())
+		}
+		if len(results) != 2 {
+			t.Errorf("Incorrect number of results, got: %d, want: %d.", len(results), 2)
+		}
+	}
+
+	expectedPaths := map[string]int{"/batch": 1, "/provisioned": 2, "/bootstrapped": 2}
+	if !reflect.DeepEqual(expectedPaths, calledPaths) {
+		t.Errorf("Mismatch paths called, want: %v, got: %v", expectedPaths, calledPaths)
+	}
+}
+
+func TestBatchTransitionNodeNotFound(t *testing.T) {
+	updates := []StatusUpdate{{"test-c-01", pfmodel.StatusProvisioned}}
+
+	var mu sync.Mutex
+	calledPaths := map[string]int{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		calledPaths[req.URL.Path]++
+		mu.Unlock()
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte(`{"error": "node not found"}`))
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"BatchUpdateStatus": "batch",
+		"MarkProvisioned":   "provisioned",
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := pfclient.BatchTransition(context.Background(), "test-01", updates); err == nil {
+			t.Errorf("Updates of an unknown node should fail")
+		}
+	}
+
+	// The node was unknown, the batch endpoint must still be used
+	expectedPaths := map[string]int{"/batch": 2, "/provisioned": 2}
+	if !reflect.DeepEqual(expectedPaths, calledPaths) {
+		t.Errorf("Mismatch paths called, want: %v, got: %v", expectedPaths, calledPaths)
+	}
+}
+
+func TestBatchTransitionFallbackCancelled(t *testing.T) {
+	updates := []StatusUpdate{{"test-c-01", pfmodel.StatusProvisioned}}
+
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/batch" {
+			res.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		<-release
+	}))
+	defer func() { testServer.Close() }()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{
+		"BatchUpdateStatus": "batch",
+		"MarkProvisioned":   "provisioned",
+	})
+	results, _ := pfclient.BatchTransition(ctx, "test-01", updates)
+	if results[0].Err == nil || ctx.Err() == nil {
+		t.Errorf("Individual updates should stop with the context, got: %v", results[0].Err)
+	}
+}
+
+func TestFetchScheduledContainersFromServerCache(t *testing.T) {
+	b := []byte(`{"api_version": "1.0", "data": {"items": [{"hostname": "test-c-01", "status": "SCHEDULED"}]}}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2018 00:00:00 GMT" {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("Last-Modified", "Mon, 01 Jan 2018 00:00:00 GMT")
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	cache := pfcache.New()
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{}, WithCache(cache))
+	for i := 0; i < 3; i++ {
+		cl, err := pfclient.FetchScheduledContainersFromServer("test-01")
+		if err != nil || len(*cl) != 1 || (*cl)[0].Hostname != "test-c-01" {
+			t.Errorf("Incorrect containers fetched, got: %v, %v", cl, err)
+		}
+	}
+
+	if cache.Hits() != 2 || cache.Misses() != 1 {
+		t.Errorf("Incorrect cache counters, got: %+v", cache.Stats())
+	}
+}
+
+func TestFetchScheduledContainersFromServerCacheCopies(t *testing.T) {
+	b := []byte(`{"api_version": "1.0", "data": {"items": [
+		{"hostname": "test-c-01", "bootstrappers": [{"bootstrap_type": "chef-solo", "bootstrap_attributes": {"run_list": ["role[consul]"]}}]}
+	]}}`)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+		res.Header().Set("ETag", `"v1"`)
+		res.WriteHeader(http.StatusOK)
+		res.Write(b)
+	}))
+	defer func() { testServer.Close() }()
+
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{}, WithCache(pfcache.New()))
+	cl, _ := pfclient.FetchScheduledContainersFromServer("test-01")
+	(*cl)[0].Bootstrappers[0].Attributes.(map[string]interface{})["run_list"] = nil
+
+	cl, err := pfclient.FetchScheduledContainersFromServer("test-01")
+	if err != nil {
+		t.Fatalf("Error when fetching cached containers: %s", err.Error())
+	}
+	if runList := (*cl)[0].Bootstrappers[0].Attributes.(map[string]interface{})["run_list"]; runList == nil {
+		t.Errorf("Cached bootstrap attributes should not be changed through a returned container")
+	}
+}
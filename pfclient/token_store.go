@@ -0,0 +1,154 @@
+package pfclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists the authentication token issued to a node on
+// registration, along with the ip address it was registered with, so it can
+// be reused after a restart.
+type TokenStore interface {
+	// Load returns the saved token and ip address, or empty strings when
+	// there is none.
+	Load(cluster, node string) (token, ipaddress string, err error)
+	Save(cluster, node, token, ipaddress string) error
+	Delete(cluster, node string) error
+}
+
+type savedToken struct {
+	Token     string `json:"token"`
+	Ipaddress string `json:"ipaddress"`
+}
+
+func tokenKey(cluster, node string) string {
+	return cluster + "/" + node
+}
+
+// FileTokenStore keeps tokens in a JSON file only readable by its owner.
+type FileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) Load(cluster, node string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return "", "", err
+	}
+	saved := tokens[tokenKey(cluster, node)]
+	return saved.Token, saved.Ipaddress, nil
+}
+
+func (s *FileTokenStore) Save(cluster, node, token, ipaddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[tokenKey(cluster, node)] = savedToken{Token: token, Ipaddress: ipaddress}
+	return s.write(tokens)
+}
+
+func (s *FileTokenStore) Delete(cluster, node string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[tokenKey(cluster, node)]; !ok {
+		return nil
+	}
+	delete(tokens, tokenKey(cluster, node))
+	return s.write(tokens)
+}
+
+func (s *FileTokenStore) read() (map[string]savedToken, error) {
+	tokens := make(map[string]savedToken)
+
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// write replaces the file atomically so a crash never leaves a truncated
+// token behind.
+func (s *FileTokenStore) write(tokens map[string]savedToken) error {
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// MemoryTokenStore keeps tokens for the lifetime of the process, mostly
+// useful in tests.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]savedToken
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]savedToken)}
+}
+
+func (s *MemoryTokenStore) Load(cluster, node string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := s.tokens[tokenKey(cluster, node)]
+	return saved.Token, saved.Ipaddress, nil
+}
+
+func (s *MemoryTokenStore) Save(cluster, node, token, ipaddress string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[tokenKey(cluster, node)] = savedToken{Token: token, Ipaddress: ipaddress}
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(cluster, node string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, tokenKey(cluster, node))
+	return nil
+}
@@ -0,0 +1,50 @@
+package pfclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pfclient")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	store := NewFileTokenStore(path)
+
+	token, _, err := store.Load("default", "test-01")
+	if err != nil || token != "" {
+		t.Errorf("Expected no token before saving, got: %q (%v)", token, err)
+	}
+
+	store.Save("default", "test-01", "123", "127.0.0.1")
+	store.Save("staging", "test-01", "456", "127.0.0.2")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Token file not written: %s", err.Error())
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Incorrect token file permissions, got: %o, want: %o.", info.Mode().Perm(), 0600)
+	}
+
+	token, ipaddress, _ := NewFileTokenStore(path).Load("default", "test-01")
+	if token != "123" {
+		t.Errorf("Incorrect token loaded, got: %q, want: %q.", token, "123")
+	}
+	if ipaddress != "127.0.0.1" {
+		t.Errorf("Incorrect ip address loaded, got: %q, want: %q.", ipaddress, "127.0.0.1")
+	}
+
+	store.Delete("default", "test-01")
+	token, _, _ = store.Load("default", "test-01")
+	if token != "" {
+		t.Errorf("Token should be deleted, got: %q", token)
+	}
+	token, _, _ = store.Load("staging", "test-01")
+	if token != "456" {
+		t.Errorf("Tokens of other clusters should be kept, got: %q", token)
+	}
+}
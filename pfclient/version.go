@@ -0,0 +1,49 @@
+package pfclient
+
+import (
+	"io"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/decode"
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/version"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+// decoderSet holds the response decoders for one major API version.
+type decoderSet struct {
+	register      func([]byte) (*RegisterDataRes, error)
+	batch         func([]byte) (*BatchTransitionDataRes, error)
+	containerList version.ContainerListDecoder
+}
+
+// decoderSets is keyed by major API version. Servers reporting a major
+// without its own set are decoded with the supported one.
+var decoderSets = version.Sets{
+	1: &decoderSet{
+		register:      NewRegisterFromByte,
+		batch:         NewBatchTransitionFromByte,
+		containerList: decode.ContainerList,
+	},
+}
+
+// ServerAPIVersion returns the api_version last reported by the server, or
+// an empty string before the first response.
+func (p *pfclient) ServerAPIVersion() string {
+	return p.versions.Server()
+}
+
+func (p *pfclient) decoders() *decoderSet {
+	return p.versions.Select(decoderSets).(*decoderSet)
+}
+
+// decodersFor observes the api_version of the response body b and returns
+// the decoder set matching it.
+func (p *pfclient) decodersFor(b []byte) (*decoderSet, error) {
+	if err := p.versions.ObserveBody(b); err != nil {
+		return nil, err
+	}
+	return p.decoders(), nil
+}
+
+func (p *pfclient) readContainerList(r io.Reader) (*pfmodel.ContainerList, error) {
+	return p.versions.ReadContainerList(r, p.decoders().containerList)
+}
@@ -0,0 +1,223 @@
+package pfclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/internal/transport"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	watchMinInterval  = time.Second
+	watchMaxInterval  = 30 * time.Second
+	watchLongPollWait = 30 * time.Second
+	maxEventSize      = 16 << 20
+)
+
+// WatchEvent carries the latest scheduled containers of a node together with
+// what changed since the previous event. Err is set instead when fetching
+// failed; the watch keeps going after an error.
+type WatchEvent struct {
+	Containers pfmodel.ContainerList
+	Diff       pfmodel.ContainerListDiff
+	Err        error
+}
+
+// WatchScheduledContainers streams changes to the containers scheduled on
+// node until ctx is done, at which point the channel is closed. The first
+// event lists every scheduled container as added.
+//
+// The server may answer with Server-Sent Events whose data are container list
+// responses, or hold the request for up to the requested wait and flag that
+// with an X-Long-Poll header. Otherwise the watch polls, backing off while
+// nothing changes and relying on ETag to keep unchanged polls cheap.
+func (p *pfclient) WatchScheduledContainers(ctx context.Context, node string) (<-chan WatchEvent, error) {
+	w, err := newWatcher(p, node)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	events := make(chan WatchEvent)
+	go w.run(ctx, events)
+	return events, nil
+}
+
+type watcher struct {
+	p           *pfclient
+	req         *transport.Request
+	minInterval time.Duration
+	maxInterval time.Duration
+
+	etag    string
+	current pfmodel.ContainerList
+	started bool
+}
+
+func newWatcher(p *pfclient, node string) (*watcher, error) {
+	// Reject a malformed server address up front rather than on every poll
+	path := p.pfApiPath["ListScheduledContainers"]
+	if _, err := url.Parse(fmt.Sprintf("%s/%s", p.pfServerAddr, path)); err != nil {
+		return nil, err
+	}
+
+	q := p.query(node)
+	q.Set("wait", strconv.Itoa(int(watchLongPollWait/time.Second)))
+
+	return &watcher{
+		p: p,
+		req: &transport.Request{
+			Operation: "WatchScheduledContainers",
+			Method:    http.MethodGet,
+			Path:      path,
+			Query:     q,
+			LongPoll:  true,
+		},
+		minInterval: watchMinInterval,
+		maxInterval: watchMaxInterval,
+	}, nil
+}
+
+func (w *watcher) run(ctx context.Context, events chan<- WatchEvent) {
+	defer close(events)
+
+	interval := w.minInterval
+	for {
+		changed, longPoll, err := w.poll(ctx, events)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error(err.Error())
+			if !w.send(ctx, events, WatchEvent{Err: err}) {
+				return
+			}
+		}
+
+		switch {
+		case err == nil && longPoll:
+			interval = w.minInterval
+			continue
+		case changed:
+			interval = w.minInterval
+		default:
+			interval *= 2
+			if interval > w.maxInterval {
+				interval = w.maxInterval
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// poll performs one request and emits events for whatever it returns. It
+// reports whether anything changed and whether the server long-polled.
+func (w *watcher) poll(ctx context.Context, events chan<- WatchEvent) (bool, bool, error) {
+	r := *w.req
+	r.Token = w.p.authToken()
+	r.Header = http.Header{}
+	r.Header.Set("Accept", "text/event-stream, application/json")
+	if w.etag != "" {
+		r.Header.Set("If-None-Match", w.etag)
+	}
+	res, err := w.p.transport().Send(ctx, &r)
+	if err != nil {
+		return false, false, err
+	}
+	defer transport.Close(res)
+
+	longPoll := res.Header.Get("X-Long-Poll") != ""
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return false, longPoll, nil
+	default:
+		return false, false, transport.NewError(res)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		changed, err := w.consumeStream(ctx, res.Body, events)
+		return changed, false, err
+	}
+
+	cl, err := w.p.readContainerList(res.Body)
+	if err != nil {
+		return false, false, err
+	}
+	w.etag = res.Header.Get("ETag")
+
+	return w.emit(ctx, events, *cl), longPoll, nil
+}
+
+// consumeStream reads Server-Sent Events until the stream ends, each event
+// carrying a full container list response.
+func (w *watcher) consumeStream(ctx context.Context, r io.Reader, events chan<- WatchEvent) (bool, error) {
+	changed := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if data.Len() == 0 {
+				continue
+			}
+			cl, err := w.p.readContainerList(bytes.NewReader(data.Bytes()))
+			data.Reset()
+			if err != nil {
+				return changed, err
+			}
+			if w.emit(ctx, events, *cl) {
+				changed = true
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+	}
+
+	return changed, scanner.Err()
+}
+
+// emit sends an event when cl differs from the last known list, or when it
+// is the first list seen, and reports whether it sent one.
+func (w *watcher) emit(ctx context.Context, events chan<- WatchEvent, cl pfmodel.ContainerList) bool {
+	diff := pfmodel.DiffContainerLists(w.current, cl)
+	if w.started && diff.Empty() {
+		return false
+	}
+	w.started = true
+	// The consumer owns cl and the diff, keep a copy they cannot change
+	w.current = cl.Copy()
+
+	return w.send(ctx, events, WatchEvent{Containers: cl, Diff: diff})
+}
+
+func (w *watcher) send(ctx context.Context, events chan<- WatchEvent, event WatchEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case events <- event:
+		return true
+	}
+}
@@ -0,0 +1,236 @@
+package pfclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/pflimit"
+)
+
+const (
+	watchListV1 = `{"api_version": "1.0", "data": {"items": [{"hostname": "test-c-01", "status": "SCHEDULED"}]}}`
+	watchListV2 = `{"api_version": "1.0", "data": {"items": [{"hostname": "test-c-01", "status": "SCHEDULED"}, {"hostname": "test-c-02", "status": "SCHEDULED"}]}}`
+)
+
+func startTestWatcher(t *testing.T, ctx context.Context, serverURL string, minInterval time.Duration) <-chan WatchEvent {
+	p := NewPfclient("default", "", &http.Client{}, serverURL, map[string]string{}).(*pfclient)
+	w, err := newWatcher(p, "test-01")
+	if err != nil {
+		t.Fatalf("Error when creating watcher: %s", err.Error())
+	}
+	w.minInterval = minInterval
+	w.maxInterval = 4 * minInterval
+
+	events := make(chan WatchEvent)
+	go w.run(ctx, events)
+	return events
+}
+
+func nextWatchEvent(t *testing.T, events <-chan WatchEvent) WatchEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for watch event")
+	}
+	return WatchEvent{}
+}
+
+func TestWatchScheduledContainersPolling(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	var ifNoneMatch []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		ifNoneMatch = append(ifNoneMatch, req.Header.Get("If-None-Match"))
+		mu.Unlock()
+
+		switch {
+		case n == 1:
+			res.Header().Set("ETag", `"v1"`)
+			res.WriteHeader(http.StatusOK)
+			res.Write([]byte(watchListV1))
+		case n < 4:
+			res.WriteHeader(http.StatusNotModified)
+		default:
+			res.Header().Set("ETag", `"v2"`)
+			res.WriteHeader(http.StatusOK)
+			res.Write([]byte(watchListV2))
+		}
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startTestWatcher(t, ctx, testServer.URL, time.Millisecond)
+
+	first := nextWatchEvent(t, events)
+	if first.Err != nil || len(first.Diff.Added) != 1 {
+		t.Errorf("Incorrect first event, got: %+v", first)
+	}
+
+	second := nextWatchEvent(t, events)
+	if second.Err != nil || len(second.Diff.Added) != 1 || second.Diff.Added[0].Hostname != "test-c-02" {
+		t.Errorf("Incorrect second event, got: %+v", second)
+	}
+	if len(second.Containers) != 2 {
+		t.Errorf("Incorrect number of containers, got: %d, want: %d.", len(second.Containers), 2)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if ifNoneMatch[0] != "" || ifNoneMatch[1] != `"v1"` {
+		t.Errorf("Incorrect If-None-Match headers sent, got: %q", ifNoneMatch)
+	}
+}
+
+func TestWatchScheduledContainersEventStream(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprintf(res, ": keep-alive\n\nevent: containers\ndata: %s\n\ndata: %s\n\ndata: %s\n\n", watchListV1, watchListV1, watchListV2)
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startTestWatcher(t, ctx, testServer.URL, time.Hour)
+
+	first := nextWatchEvent(t, events)
+	if first.Err != nil || len(first.Containers) != 1 {
+		t.Errorf("Incorrect first event, got: %+v", first)
+	}
+
+	second := nextWatchEvent(t, events)
+	if second.Err != nil || len(second.Diff.Added) != 1 || second.Diff.Added[0].Hostname != "test-c-02" {
+		t.Errorf("Incorrect second event, got: %+v", second)
+	}
+}
+
+func TestWatchScheduledContainersConsumerOwnsEvents(t *testing.T) {
+	mutated := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprintf(res, "data: %s\n\n", watchListV1)
+		res.(http.Flusher).Flush()
+		<-mutated
+		fmt.Fprintf(res, "data: %s\n\ndata: %s\n\n", watchListV1, watchListV2)
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startTestWatcher(t, ctx, testServer.URL, time.Hour)
+
+	first := nextWatchEvent(t, events)
+	first.Containers[0].Status = "MUTATED"
+	close(mutated)
+
+	second := nextWatchEvent(t, events)
+	if second.Err != nil || len(second.Diff.Added) != 1 || len(second.Diff.Modified) != 0 {
+		t.Errorf("Changes made by the consumer should not show in the diff, got: %+v", second)
+	}
+}
+
+func TestWatchScheduledContainersLongPoll(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+
+		if req.URL.Query().Get("wait") == "" {
+			t.Errorf("Watch request should ask the server to wait")
+		}
+
+		res.Header().Set("X-Long-Poll", "true")
+		res.WriteHeader(http.StatusOK)
+		if n == 1 {
+			res.Write([]byte(watchListV1))
+		} else {
+			res.Write([]byte(`{"api_version": "1.0", "data": {"items": []}}`))
+		}
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := startTestWatcher(t, ctx, testServer.URL, time.Hour)
+
+	nextWatchEvent(t, events)
+	second := nextWatchEvent(t, events)
+	if len(second.Diff.Removed) != 1 || second.Diff.Removed[0].Hostname != "test-c-01" {
+		t.Errorf("Incorrect second event, got: %+v", second)
+	}
+}
+
+func TestWatchScheduledContainersClosesOnCancel(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(watchListV1))
+	}))
+	defer func() { testServer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pfclient := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{})
+	events, err := pfclient.WatchScheduledContainers(ctx, "test-01")
+	if err != nil {
+		t.Fatalf("Error when watching containers: %s", err.Error())
+	}
+
+	event := nextWatchEvent(t, events)
+	if event.Containers.FindByHostname("test-c-01") == -1 {
+		t.Errorf("Incorrect first event, got: %+v", event)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("No event expected after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Watch channel not closed after cancellation")
+	}
+}
+
+func TestWatchScheduledContainersWithLimiter(t *testing.T) {
+	watching := make(chan struct{}, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("wait") != "" {
+			watching <- struct{}{}
+			<-req.Context().Done()
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(watchListV1))
+	}))
+	defer func() { testServer.Close() }()
+
+	limiter := pflimit.New(pflimit.Config{MaxInFlight: 1}, pflimit.Config{})
+	p := NewPfclient("default", "", &http.Client{}, testServer.URL, map[string]string{},
+		WithLimiter(limiter))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := p.WatchScheduledContainers(ctx, "test-01"); err != nil {
+		t.Fatalf("Error when watching containers: %s", err.Error())
+	}
+	<-watching
+
+	fetchCtx, fetchCancel := context.WithTimeout(context.Background(), time.Second)
+	defer fetchCancel()
+	if _, err := p.WithContext(fetchCtx).FetchScheduledContainersFromServer("test-01"); err != nil {
+		t.Errorf("Pending watch should not hold the only read slot, got: %v", err)
+	}
+}
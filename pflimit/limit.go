@@ -0,0 +1,141 @@
+// Package pflimit throttles requests sent to Pathfinder server with a token
+// bucket and a cap on requests in flight, separately for reads and writes.
+package pflimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Rate is the sustained number of requests per second, zero means
+	// unlimited.
+	Rate float64
+	// Burst is the number of requests allowed at once above Rate, defaults
+	// to Rate rounded up.
+	Burst int
+	// MaxInFlight caps concurrent requests, zero means unlimited.
+	MaxInFlight int
+}
+
+// Limiter is safe for concurrent use and may be shared between clients to
+// throttle them together. A nil *Limiter does not throttle.
+type Limiter struct {
+	reads  *class
+	writes *class
+}
+
+// New returns a limiter applying reads to GET and HEAD requests and writes
+// to every other method.
+func New(reads, writes Config) *Limiter {
+	return &Limiter{
+		reads:  newClass(reads),
+		writes: newClass(writes),
+	}
+}
+
+// Wait blocks until a request with the given method may be sent, or ctx is
+// done. The returned release must be called once the request completed.
+func (l *Limiter) Wait(ctx context.Context, method string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	c := l.writes
+	if method == http.MethodGet || method == http.MethodHead {
+		c = l.reads
+	}
+	return c.wait(ctx)
+}
+
+type class struct {
+	rate  float64
+	burst float64
+	slots chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newClass(cfg Config) *class {
+	c := &class{rate: cfg.Rate}
+	if cfg.Rate > 0 {
+		c.burst = float64(cfg.Burst)
+		if c.burst <= 0 {
+			c.burst = math.Ceil(cfg.Rate)
+		}
+		c.tokens = c.burst
+	}
+	if cfg.MaxInFlight > 0 {
+		c.slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return c
+}
+
+func (c *class) wait(ctx context.Context) (func(), error) {
+	if err := c.take(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case c.slots <- struct{}{}:
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-c.slots })
+	}, nil
+}
+
+// take waits for a token of the bucket, giving it back when ctx is done
+// before the wait is over.
+func (c *class) take(ctx context.Context) error {
+	if c.rate <= 0 {
+		return ctx.Err()
+	}
+
+	delay := c.reserve()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.tokens = math.Min(c.burst, c.tokens+1)
+		c.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token, possibly going into debt, and returns how long to
+// wait until the debt is paid off.
+func (c *class) reserve() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if !c.last.IsZero() {
+		c.tokens = math.Min(c.burst, c.tokens+now.Sub(c.last).Seconds()*c.rate)
+	}
+	c.last = now
+
+	c.tokens--
+	if c.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-c.tokens / c.rate * float64(time.Second))
+}
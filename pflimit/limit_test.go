@@ -0,0 +1,70 @@
+package pflimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	limiter := New(Config{Rate: 20, Burst: 1}, Config{})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := limiter.Wait(context.Background(), http.MethodGet)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Reads should be throttled to 20 per second, 3 took %s", elapsed)
+	}
+
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		release, _ := limiter.Wait(context.Background(), http.MethodPost)
+		release()
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Writes should not be throttled, 10 took %s", elapsed)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	limiter := New(Config{}, Config{MaxInFlight: 1})
+
+	release, err := limiter.Wait(context.Background(), http.MethodDelete)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx, http.MethodPost); err != context.DeadlineExceeded {
+		t.Errorf("Second write should wait for the first one, got: %v", err)
+	}
+
+	release()
+	release()
+	if _, err := limiter.Wait(context.Background(), http.MethodPost); err != nil {
+		t.Errorf("Released slot should be reusable, got: %v", err)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	limiter := New(Config{Rate: 1, Burst: 1}, Config{})
+	limiter.Wait(context.Background(), http.MethodGet)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Wait(ctx, http.MethodGet); err != context.Canceled {
+		t.Errorf("Cancelled wait should return ctx.Err(), got: %v", err)
+	}
+
+	var nilLimiter *Limiter
+	if _, err := nilLimiter.Wait(context.Background(), http.MethodGet); err != nil {
+		t.Errorf("Nil limiter should not throttle, got: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+package pfmodel
+
+import (
+	"time"
+)
+
+type Cluster struct {
+	Name      string
+	CreatedAt time.Time
+}
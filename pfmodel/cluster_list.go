@@ -0,0 +1,12 @@
+package pfmodel
+
+type ClusterList []Cluster
+
+func (cl ClusterList) FindByName(name string) int {
+	for i, c := range cl {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
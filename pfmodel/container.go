@@ -1,5 +1,20 @@
 package pfmodel
 
+const (
+	StatusPending            = "PENDING"
+	StatusScheduled          = "SCHEDULED"
+	StatusProvisioned        = "PROVISIONED"
+	StatusProvisionError     = "PROVISION_ERROR"
+	StatusBootstrapStarted   = "BOOTSTRAP_STARTED"
+	StatusBootstrapped       = "BOOTSTRAPPED"
+	StatusBootstrapError     = "BOOTSTRAP_ERROR"
+	StatusScheduleRelocation = "SCHEDULE_RELOCATION"
+	StatusRelocateStarted    = "RELOCATE_STARTED"
+	StatusRelocateError      = "RELOCATE_ERROR"
+	StatusScheduleDeletion   = "SCHEDULE_DELETION"
+	StatusDeleted            = "DELETED"
+)
+
 type Container struct {
 	Hostname      string `json:"hostname"`
 	Ipaddress     string `json:"ipaddress"`
@@ -28,3 +43,35 @@ type Bootstrapper struct {
 	CookbooksUrl string      `json:"bootstrap_cookbooks_url"`
 	Attributes   interface{} `json:"bootstrap_attributes"`
 }
+
+// Copy returns a container that shares no memory with c.
+func (c Container) Copy() Container {
+	if c.Bootstrappers != nil {
+		bootstrappers := make([]Bootstrapper, len(c.Bootstrappers))
+		for i, b := range c.Bootstrappers {
+			b.Attributes = copyValue(b.Attributes)
+			bootstrappers[i] = b
+		}
+		c.Bootstrappers = bootstrappers
+	}
+	return c
+}
+
+// copyValue deep copies the maps and slices of a decoded JSON value.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = copyValue(e)
+		}
+		return s
+	}
+	return v
+}
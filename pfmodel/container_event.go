@@ -0,0 +1,18 @@
+package pfmodel
+
+import (
+	"time"
+)
+
+// ContainerEvent is a status transition of a container, as recorded by
+// Pathfinder server.
+type ContainerEvent struct {
+	Hostname       string
+	Status         string
+	PreviousStatus string
+	NodeHostname   string
+	// Actor is who caused the transition, e.g. a node agent or an ext user.
+	Actor     string
+	Message   string
+	CreatedAt time.Time
+}
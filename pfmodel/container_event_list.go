@@ -0,0 +1,27 @@
+package pfmodel
+
+import (
+	"sort"
+)
+
+// ContainerEventList is a timeline of container events, oldest first.
+type ContainerEventList []ContainerEvent
+
+// SortByTime orders the events from oldest to newest, keeping the server's
+// order for events recorded at the same time.
+func (el ContainerEventList) SortByTime() {
+	sort.SliceStable(el, func(i, j int) bool {
+		return el[i].CreatedAt.Before(el[j].CreatedAt)
+	})
+}
+
+// LastWithStatus returns the index of the latest event that moved the
+// container into status, or -1 when there is none.
+func (el ContainerEventList) LastWithStatus(status string) int {
+	for i := len(el) - 1; i >= 0; i-- {
+		if el[i].Status == status {
+			return i
+		}
+	}
+	return -1
+}
@@ -11,6 +11,18 @@ func (cl ContainerList) FindByHostname(hostname string) int {
 	return -1
 }
 
+// Copy returns a list that shares no memory with cl.
+func (cl ContainerList) Copy() ContainerList {
+	if cl == nil {
+		return nil
+	}
+	res := make(ContainerList, len(cl))
+	for i, c := range cl {
+		res[i] = c.Copy()
+	}
+	return res
+}
+
 func (cl ContainerList) DeleteAt(i int) bool {
 	cl = append(cl[:i], cl[i+1:]...)
 	return true
@@ -0,0 +1,100 @@
+package pfmodel
+
+import (
+	"reflect"
+)
+
+type ContainerField string
+
+const (
+	ContainerFieldStatus        ContainerField = "status"
+	ContainerFieldIpaddress     ContainerField = "ipaddress"
+	ContainerFieldNodeHostname  ContainerField = "node_hostname"
+	ContainerFieldSource        ContainerField = "source"
+	ContainerFieldBootstrappers ContainerField = "bootstrappers"
+)
+
+// ContainerChange describes a container present in both lists whose
+// content differs between them.
+type ContainerChange struct {
+	Old    Container
+	New    Container
+	Fields []ContainerField
+}
+
+func (cc ContainerChange) Changed(field ContainerField) bool {
+	for _, f := range cc.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+type ContainerListDiff struct {
+	Added    ContainerList
+	Removed  ContainerList
+	Modified []ContainerChange
+}
+
+func (d ContainerListDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// DiffContainerLists compares two snapshots of containers keyed by hostname.
+// Added and Modified follow the order of newList, Removed follows oldList.
+func DiffContainerLists(oldList, newList ContainerList) ContainerListDiff {
+	var diff ContainerListDiff
+
+	oldByHostname := make(map[string]int, len(oldList))
+	for i, c := range oldList {
+		oldByHostname[c.Hostname] = i
+	}
+
+	seen := make(map[string]bool, len(newList))
+	for _, c := range newList {
+		seen[c.Hostname] = true
+		i, ok := oldByHostname[c.Hostname]
+		if !ok {
+			diff.Added = append(diff.Added, c)
+			continue
+		}
+
+		fields := diffContainer(oldList[i], c)
+		if len(fields) > 0 {
+			diff.Modified = append(diff.Modified, ContainerChange{
+				Old:    oldList[i],
+				New:    c,
+				Fields: fields,
+			})
+		}
+	}
+
+	for _, c := range oldList {
+		if !seen[c.Hostname] {
+			diff.Removed = append(diff.Removed, c)
+		}
+	}
+
+	return diff
+}
+
+func diffContainer(a, b Container) []ContainerField {
+	var fields []ContainerField
+	if a.Status != b.Status {
+		fields = append(fields, ContainerFieldStatus)
+	}
+	if a.Ipaddress != b.Ipaddress {
+		fields = append(fields, ContainerFieldIpaddress)
+	}
+	if a.NodeHostname != b.NodeHostname {
+		fields = append(fields, ContainerFieldNodeHostname)
+	}
+	if a.Source != b.Source {
+		fields = append(fields, ContainerFieldSource)
+	}
+	if !reflect.DeepEqual(a.Bootstrappers, b.Bootstrappers) {
+		fields = append(fields, ContainerFieldBootstrappers)
+	}
+	return fields
+}
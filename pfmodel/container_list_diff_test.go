@@ -0,0 +1,85 @@
+package pfmodel
+
+import (
+	"testing"
+)
+
+func TestDiffContainerLists(t *testing.T) {
+	source := Source{Type: "image", Mode: "pull", Alias: "16.04"}
+	oldList := ContainerList{
+		{Hostname: "test-01", Status: "SCHEDULED", Source: source},
+		{Hostname: "test-02", Status: "SCHEDULED", Source: source},
+		{Hostname: "test-03", Status: "PROVISIONED", Ipaddress: "10.0.0.3", Source: source},
+	}
+	newList := ContainerList{
+		{Hostname: "test-01", Status: "SCHEDULED", Source: source},
+		{Hostname: "test-03", Status: "BOOTSTRAPPED", Ipaddress: "10.0.0.4", Source: source},
+		{Hostname: "test-04", Status: "SCHEDULED", Source: source},
+	}
+
+	diff := DiffContainerLists(oldList, newList)
+
+	if len(diff.Added) != 1 || diff.Added[0].Hostname != "test-04" {
+		t.Errorf("Incorrect added containers, got: %v, want: %s.", diff.Added, "test-04")
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].Hostname != "test-02" {
+		t.Errorf("Incorrect removed containers, got: %v, want: %s.", diff.Removed, "test-02")
+	}
+
+	if len(diff.Modified) != 1 {
+		t.Fatalf("Incorrect number of modified containers, got: %d, want: %d.", len(diff.Modified), 1)
+	}
+
+	change := diff.Modified[0]
+	if change.New.Hostname != "test-03" {
+		t.Errorf("Incorrect modified container, got: %s, want: %s.", change.New.Hostname, "test-03")
+	}
+
+	tables := []struct {
+		field   ContainerField
+		changed bool
+	}{
+		{ContainerFieldStatus, true},
+		{ContainerFieldIpaddress, true},
+		{ContainerFieldNodeHostname, false},
+		{ContainerFieldSource, false},
+		{ContainerFieldBootstrappers, false},
+	}
+	for _, table := range tables {
+		if change.Changed(table.field) != table.changed {
+			t.Errorf("Incorrect change detection for %s, got: %t, want: %t.",
+				table.field,
+				change.Changed(table.field),
+				table.changed)
+		}
+	}
+}
+
+func TestDiffContainerListsBootstrappers(t *testing.T) {
+	oldList := ContainerList{
+		{
+			Hostname: "test-01",
+			Bootstrappers: []Bootstrapper{
+				{Type: "chef-solo", Attributes: map[string]interface{}{"run_list": []interface{}{"role[consul]"}}},
+			},
+		},
+	}
+	newList := ContainerList{
+		{
+			Hostname: "test-01",
+			Bootstrappers: []Bootstrapper{
+				{Type: "chef-solo", Attributes: map[string]interface{}{"run_list": []interface{}{"role[redis]"}}},
+			},
+		},
+	}
+
+	diff := DiffContainerLists(oldList, newList)
+	if len(diff.Modified) != 1 || !diff.Modified[0].Changed(ContainerFieldBootstrappers) {
+		t.Errorf("Bootstrapper attribute change not detected, got: %v", diff.Modified)
+	}
+
+	if !DiffContainerLists(oldList, oldList).Empty() {
+		t.Errorf("Diff of identical lists should be empty")
+	}
+}
@@ -0,0 +1,64 @@
+package pfmodel
+
+import (
+	"unicode/utf8"
+)
+
+const (
+	PhaseProvision = "provision"
+	PhaseBootstrap = "bootstrap"
+	PhaseRelocate  = "relocate"
+)
+
+// Size caps applied to an ErrorReason before it is sent, in bytes.
+const (
+	MaxErrorMessageSize = 1 << 10
+	MaxErrorLogTailSize = 16 << 10
+)
+
+const truncationMarker = "..."
+
+// ErrorReason explains why a container transitioned to an error status.
+type ErrorReason struct {
+	Message  string
+	ExitCode int
+	// LogTail is the end of the failing command's output.
+	LogTail string
+	Phase   string
+	// Truncated is set when Message or LogTail was cut to fit the caps.
+	Truncated bool
+}
+
+// Truncate returns a copy of r fitting the size caps. The message keeps its
+// beginning and the log tail its end, where failures are usually reported.
+func (r ErrorReason) Truncate() ErrorReason {
+	if len(r.Message) > MaxErrorMessageSize {
+		r.Message = truncateHead(r.Message, MaxErrorMessageSize)
+		r.Truncated = true
+	}
+	if len(r.LogTail) > MaxErrorLogTailSize {
+		r.LogTail = truncateTail(r.LogTail, MaxErrorLogTailSize)
+		r.Truncated = true
+	}
+	return r
+}
+
+// truncateHead keeps the first bytes of s, cut on a rune boundary, followed
+// by a marker, within max bytes.
+func truncateHead(s string, max int) string {
+	n := max - len(truncationMarker)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + truncationMarker
+}
+
+// truncateTail keeps the last bytes of s, cut on a rune boundary, preceded
+// by a marker, within max bytes.
+func truncateTail(s string, max int) string {
+	n := len(s) - (max - len(truncationMarker))
+	for n < len(s) && !utf8.RuneStart(s[n]) {
+		n++
+	}
+	return truncationMarker + s[n:]
+}
@@ -0,0 +1,32 @@
+package pfmodel
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestErrorReasonTruncate(t *testing.T) {
+	reason := ErrorReason{Message: "short", LogTail: "line"}.Truncate()
+	if reason.Truncated || reason.Message != "short" || reason.LogTail != "line" {
+		t.Errorf("Reason within caps should be unchanged, got: %+v", reason)
+	}
+
+	reason = ErrorReason{
+		Message: "chef run failed: " + strings.Repeat("é", MaxErrorMessageSize),
+		LogTail: strings.Repeat("é", MaxErrorLogTailSize) + "exit status 1",
+	}.Truncate()
+
+	if !reason.Truncated {
+		t.Errorf("Reason over caps should be marked truncated")
+	}
+	if len(reason.Message) > MaxErrorMessageSize || !strings.HasPrefix(reason.Message, "chef run failed: ") {
+		t.Errorf("Incorrect truncated message, got %d bytes: %q...", len(reason.Message), reason.Message[:20])
+	}
+	if len(reason.LogTail) > MaxErrorLogTailSize || !strings.HasSuffix(reason.LogTail, "exit status 1") {
+		t.Errorf("Incorrect truncated log tail, got %d bytes ending with: %q", len(reason.LogTail), reason.LogTail[len(reason.LogTail)-20:])
+	}
+	if !utf8.ValidString(reason.Message) || !utf8.ValidString(reason.LogTail) {
+		t.Errorf("Truncation should not split runes")
+	}
+}
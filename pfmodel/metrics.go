@@ -25,3 +25,26 @@ type Disk struct {
 	Total uint64 `json:"total"`
 	Used  uint64 `json:"used"`
 }
+
+// Copy returns metrics that share no memory with m.
+func (m Metrics) Copy() Metrics {
+	if m.Memory != nil {
+		memory := *m.Memory
+		m.Memory = &memory
+	}
+	if m.Load != nil {
+		load := *m.Load
+		m.Load = &load
+	}
+	m.RootDisk = m.RootDisk.copy()
+	m.ZFSDisk = m.ZFSDisk.copy()
+	return m
+}
+
+func (d *Disk) copy() *Disk {
+	if d == nil {
+		return nil
+	}
+	disk := *d
+	return &disk
+}
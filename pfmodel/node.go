@@ -1,10 +1,37 @@
 package pfmodel
 
+import (
+	"time"
+)
+
 type Node struct {
-	Hostname   string
-	Ipaddress  string
-	CreatedAt  string
-	MemFreeMb  uint64
-	MemUsedMb  uint64
-	MemTotalMb uint64
+	Hostname         string
+	Ipaddress        string
+	CreatedAt        time.Time
+	MemFreeMb        uint64
+	MemUsedMb        uint64
+	MemTotalMb       uint64
+	Metrics          Metrics
+	LastHeartbeatAt  time.Time
+	MetricsUpdatedAt time.Time
+	Schedulable      bool
+	Cordoned         bool
+}
+
+func (n Node) Capacity() Capacity {
+	return Capacity{
+		MemFreeMb:  n.MemFreeMb,
+		MemUsedMb:  n.MemUsedMb,
+		MemTotalMb: n.MemTotalMb,
+	}
+}
+
+func (n Node) MemUtilization() float64 {
+	return n.Capacity().MemUtilization()
+}
+
+// Copy returns a node that shares no memory with n.
+func (n Node) Copy() Node {
+	n.Metrics = n.Metrics.Copy()
+	return n
 }
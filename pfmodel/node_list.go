@@ -1,3 +1,99 @@
 package pfmodel
 
+import (
+	"sort"
+)
+
 type NodeList []Node
+
+type Capacity struct {
+	MemFreeMb  uint64
+	MemUsedMb  uint64
+	MemTotalMb uint64
+}
+
+// MemUtilization returns used memory as a percentage of total memory.
+func (c Capacity) MemUtilization() float64 {
+	if c.MemTotalMb == 0 {
+		return 0
+	}
+	return float64(c.MemUsedMb) / float64(c.MemTotalMb) * 100
+}
+
+func (nl NodeList) FindByHostname(hostname string) int {
+	for i, n := range nl {
+		if n.Hostname == hostname {
+			return i
+		}
+	}
+	return -1
+}
+
+// Copy returns a list that shares no memory with nl.
+func (nl NodeList) Copy() NodeList {
+	if nl == nil {
+		return nil
+	}
+	res := make(NodeList, len(nl))
+	for i, n := range nl {
+		res[i] = n.Copy()
+	}
+	return res
+}
+
+// Schedulable returns the nodes that can currently accept new containers.
+func (nl NodeList) Schedulable() NodeList {
+	var res NodeList
+	for _, n := range nl {
+		if n.Schedulable {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// SortByFreeMemory sorts the list in place, node with the most free memory first.
+func (nl NodeList) SortByFreeMemory() {
+	sort.SliceStable(nl, func(i, j int) bool {
+		return nl[i].MemFreeMb > nl[j].MemFreeMb
+	})
+}
+
+func (nl NodeList) TotalCapacity() Capacity {
+	var c Capacity
+	for _, n := range nl {
+		c.MemFreeMb += n.MemFreeMb
+		c.MemUsedMb += n.MemUsedMb
+		c.MemTotalMb += n.MemTotalMb
+	}
+	return c
+}
+
+func (nl NodeList) MemUtilization() float64 {
+	return nl.TotalCapacity().MemUtilization()
+}
+
+// BestFit returns the index of the node with the least free memory that can
+// still hold memMb, skipping the excluded hostnames. It returns -1 when no
+// node fits.
+func (nl NodeList) BestFit(memMb uint64, exclude ...string) int {
+	best := -1
+	for i, n := range nl {
+		if n.MemFreeMb < memMb || contains(exclude, n.Hostname) {
+			continue
+		}
+		if best == -1 || n.MemFreeMb < nl[best].MemFreeMb {
+			best = i
+		}
+	}
+	return best
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
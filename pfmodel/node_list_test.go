@@ -0,0 +1,71 @@
+package pfmodel
+
+import (
+	"testing"
+)
+
+func testNodeList() NodeList {
+	return NodeList{
+		{Hostname: "node-01", MemFreeMb: 100, MemUsedMb: 300, MemTotalMb: 400},
+		{Hostname: "node-02", MemFreeMb: 300, MemUsedMb: 100, MemTotalMb: 400},
+		{Hostname: "node-03", MemFreeMb: 150, MemUsedMb: 250, MemTotalMb: 400},
+	}
+}
+
+func TestNodeListFindByHostname(t *testing.T) {
+	nl := testNodeList()
+	if i := nl.FindByHostname("node-03"); i != 2 {
+		t.Errorf("Incorrect index found, got: %d, want: %d.", i, 2)
+	}
+	if i := nl.FindByHostname("node-99"); i != -1 {
+		t.Errorf("Incorrect index found, got: %d, want: %d.", i, -1)
+	}
+}
+
+func TestNodeListSortByFreeMemory(t *testing.T) {
+	nl := testNodeList()
+	nl.SortByFreeMemory()
+
+	expected := []string{"node-02", "node-03", "node-01"}
+	for i, hostname := range expected {
+		if nl[i].Hostname != hostname {
+			t.Errorf("Incorrect order at %d, got: %s, want: %s.", i, nl[i].Hostname, hostname)
+		}
+	}
+}
+
+func TestNodeListTotalCapacity(t *testing.T) {
+	nl := testNodeList()
+	c := nl.TotalCapacity()
+	if c.MemFreeMb != 550 || c.MemUsedMb != 650 || c.MemTotalMb != 1200 {
+		t.Errorf("Incorrect capacity, got: %+v", c)
+	}
+
+	if u := nl[0].MemUtilization(); u != 75 {
+		t.Errorf("Incorrect node utilization, got: %f, want: %f.", u, 75.0)
+	}
+
+	if u := (NodeList{}).MemUtilization(); u != 0 {
+		t.Errorf("Incorrect utilization of empty list, got: %f, want: %f.", u, 0.0)
+	}
+}
+
+func TestNodeListBestFit(t *testing.T) {
+	tables := []struct {
+		memMb   uint64
+		exclude []string
+		index   int
+	}{
+		{50, nil, 0},
+		{120, nil, 2},
+		{120, []string{"node-03"}, 1},
+		{500, nil, -1},
+	}
+
+	nl := testNodeList()
+	for _, table := range tables {
+		if i := nl.BestFit(table.memMb, table.exclude...); i != table.index {
+			t.Errorf("Incorrect best fit for %d MB, got: %d, want: %d.", table.memMb, i, table.index)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package pfsecret resolves secrets such as the cluster password or the ext
+// API token from files, environment variables or commands, so they do not
+// have to be passed around as plain strings.
+package pfsecret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in printed output.
+const Redacted = "[REDACTED]"
+
+// Provider reads the current value of a secret.
+type Provider interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+type ProviderFunc func(ctx context.Context) (string, error)
+
+func (f ProviderFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Static provides a fixed value, for callers that already hold the secret.
+func Static(value string) Provider {
+	return ProviderFunc(func(context.Context) (string, error) {
+		return value, nil
+	})
+}
+
+// Env reads the secret from the environment variable name.
+func Env(name string) Provider {
+	return ProviderFunc(func(context.Context) (string, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	})
+}
+
+// File reads the secret from path, ignoring surrounding whitespace such as
+// a trailing newline.
+func File(path string) Provider {
+	return ProviderFunc(func(context.Context) (string, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	})
+}
+
+// Command runs name with args and uses its trimmed standard output as the
+// secret, e.g. to fetch it from a vault CLI.
+func Command(name string, args ...string) Provider {
+	return ProviderFunc(func(ctx context.Context) (string, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s: %s: %s", name, err.Error(), strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	})
+}
+
+// Source resolves a secret from its provider on first use and keeps it until
+// Refresh is called, typically after the server rejected it. It is safe for
+// concurrent use and never prints the secret.
+type Source struct {
+	provider Provider
+
+	mu       sync.Mutex
+	value    string
+	resolved bool
+}
+
+func NewSource(provider Provider) *Source {
+	return &Source{provider: provider}
+}
+
+// Get returns the secret, reading it from the provider the first time.
+func (s *Source) Get(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.resolved {
+		return s.value, nil
+	}
+	return s.resolve(ctx)
+}
+
+// Refresh reads the secret from the provider again and reports whether it
+// changed, in which case a rejected request is worth retrying.
+func (s *Source) Refresh(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, wasResolved := s.value, s.resolved
+	value, err := s.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !wasResolved || value != previous, nil
+}
+
+func (s *Source) resolve(ctx context.Context) (string, error) {
+	value, err := s.provider.Secret(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.value = value
+	s.resolved = true
+	return value, nil
+}
+
+func (s *Source) String() string {
+	return Redacted
+}
+
+func (s *Source) GoString() string {
+	return Redacted
+}
@@ -0,0 +1,79 @@
+package pfsecret
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProviders(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pfsecret")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "password")
+	ioutil.WriteFile(path, []byte("from-file\n"), 0600)
+	os.Setenv("PFSECRET_TEST", "from-env")
+	defer os.Unsetenv("PFSECRET_TEST")
+
+	tables := []struct {
+		provider Provider
+		want     string
+	}{
+		{Static("static"), "static"},
+		{Env("PFSECRET_TEST"), "from-env"},
+		{File(path), "from-file"},
+		{Command("echo", "from-command"), "from-command"},
+	}
+
+	for _, table := range tables {
+		value, err := table.provider.Secret(context.Background())
+		if err != nil || value != table.want {
+			t.Errorf("Incorrect secret, got: %q (%v), want: %q.", value, err, table.want)
+		}
+	}
+
+	if _, err := Env("PFSECRET_MISSING").Secret(context.Background()); err == nil {
+		t.Errorf("Missing environment variable should be an error")
+	}
+	if _, err := File(filepath.Join(dir, "missing")).Secret(context.Background()); err == nil {
+		t.Errorf("Missing file should be an error")
+	}
+}
+
+func TestSource(t *testing.T) {
+	reads := 0
+	value := "v1"
+	source := NewSource(ProviderFunc(func(context.Context) (string, error) {
+		reads++
+		return value, nil
+	}))
+	if reads != 0 {
+		t.Errorf("Secret should be resolved lazily")
+	}
+
+	source.Get(context.Background())
+	got, _ := source.Get(context.Background())
+	if got != "v1" || reads != 1 {
+		t.Errorf("Secret should be read once, got: %q after %d reads", got, reads)
+	}
+
+	if changed, _ := source.Refresh(context.Background()); changed {
+		t.Errorf("Unchanged secret reported as changed")
+	}
+	value = "v2"
+	if changed, _ := source.Refresh(context.Background()); !changed {
+		t.Errorf("Rotated secret not reported as changed")
+	}
+	if got, _ := source.Get(context.Background()); got != "v2" {
+		t.Errorf("Incorrect secret after refresh, got: %q, want: %q.", got, "v2")
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		if out := fmt.Sprintf(format, source); out != Redacted {
+			t.Errorf("Secret leaked with %s, got: %s", format, out)
+		}
+	}
+}
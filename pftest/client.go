@@ -0,0 +1,363 @@
+// Package pftest provides an in-memory ext.Client for testing code built on
+// top of Pathfinder without a running server.
+package pftest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/ext"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+// DefaultActor is recorded on the events caused through the client.
+const DefaultActor = "pftest"
+
+var _ ext.Client = (*Client)(nil)
+
+type cluster struct {
+	info       pfmodel.Cluster
+	nodes      pfmodel.NodeList
+	containers pfmodel.ContainerList
+	events     map[string]pfmodel.ContainerEventList
+}
+
+// store is shared by the clients returned by WithCluster.
+type store struct {
+	mu       sync.Mutex
+	clusters map[string]*cluster
+	order    []string
+	now      func() time.Time
+}
+
+// Client keeps nodes, containers and their event history in memory. It is
+// safe for concurrent use.
+type Client struct {
+	cluster string
+	// Actor is recorded on the events caused through this client.
+	Actor string
+	s     *store
+}
+
+// NewClient returns a client of clusterName, which is created empty. Other
+// clusters exist once created with CreateCluster, AddNode or AddContainer.
+func NewClient(clusterName string) *Client {
+	s := &store{
+		clusters: make(map[string]*cluster),
+		now:      time.Now,
+	}
+	s.create(clusterName)
+	return &Client{cluster: clusterName, Actor: DefaultActor, s: s}
+}
+
+// SetClock replaces the time used for events and creation timestamps.
+func (c *Client) SetClock(now func() time.Time) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.now = now
+}
+
+// AddNode adds or replaces a node of the client's cluster, creating the
+// cluster if needed.
+func (c *Client) AddNode(node pfmodel.Node) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl := c.s.create(c.cluster)
+	if i := cl.nodes.FindByHostname(node.Hostname); i >= 0 {
+		cl.nodes[i] = node
+		return
+	}
+	cl.nodes = append(cl.nodes, node)
+}
+
+// AddContainer adds or replaces a container of the client's cluster without
+// recording an event, creating the cluster if needed.
+func (c *Client) AddContainer(cntr pfmodel.Container) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl := c.s.create(c.cluster)
+	if i := cl.containers.FindByHostname(cntr.Hostname); i >= 0 {
+		cl.containers[i] = cntr
+		return
+	}
+	cl.containers = append(cl.containers, cntr)
+}
+
+// SetContainerStatus moves a container to status as actor would, e.g. a
+// node agent reporting BOOTSTRAP_ERROR, and records the event.
+func (c *Client) SetContainerStatus(hostname, status, actor, message string) (*pfmodel.Container, error) {
+	return c.transition(hostname, actor, message, func(cntr *pfmodel.Container) {
+		cntr.Status = status
+	})
+}
+
+func (c *Client) WithCluster(clusterName string) ext.Client {
+	return &Client{cluster: clusterName, Actor: c.Actor, s: c.s}
+}
+
+// WithContext returns the client itself, its calls never block.
+func (c *Client) WithContext(ctx context.Context) ext.Client {
+	return c
+}
+
+func (c *Client) ServerAPIVersion() string {
+	return "1.0"
+}
+
+func (c *Client) ListClusters() (*pfmodel.ClusterList, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	clusters := pfmodel.ClusterList{}
+	for _, name := range c.s.order {
+		clusters = append(clusters, c.s.clusters[name].info)
+	}
+	return &clusters, nil
+}
+
+func (c *Client) GetCluster(name string) (*pfmodel.Cluster, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, ok := c.s.clusters[name]
+	if !ok {
+		return nil, notFound("cluster", name)
+	}
+	info := cl.info
+	return &info, nil
+}
+
+func (c *Client) CreateCluster(req ext.CreateClusterRequest) (*pfmodel.Cluster, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if _, ok := c.s.clusters[req.Name]; ok {
+		return nil, &ext.APIError{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    fmt.Sprintf("cluster %s already exists", req.Name),
+		}
+	}
+	info := c.s.create(req.Name).info
+	return &info, nil
+}
+
+func (c *Client) GetNodes() (*pfmodel.NodeList, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	nodes := append(pfmodel.NodeList{}, cl.nodes...)
+	return &nodes, nil
+}
+
+func (c *Client) GetNode(hostname string) (*pfmodel.Node, error) {
+	return c.updateNode(hostname, func(*pfmodel.Node) {})
+}
+
+func (c *Client) CordonNode(hostname string) (*pfmodel.Node, error) {
+	return c.updateNode(hostname, func(n *pfmodel.Node) {
+		n.Schedulable = false
+		n.Cordoned = true
+	})
+}
+
+func (c *Client) UncordonNode(hostname string) (*pfmodel.Node, error) {
+	return c.updateNode(hostname, func(n *pfmodel.Node) {
+		n.Schedulable = true
+		n.Cordoned = false
+	})
+}
+
+func (c *Client) DeleteNode(hostname string) (*pfmodel.Node, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	i := cl.nodes.FindByHostname(hostname)
+	if i < 0 {
+		return nil, notFound("node", hostname)
+	}
+	node := cl.nodes[i]
+	cl.nodes = append(cl.nodes[:i], cl.nodes[i+1:]...)
+	return &node, nil
+}
+
+// EvacuateNode cordons the node and schedules the relocation of its live
+// containers to any node.
+func (c *Client) EvacuateNode(hostname string) (*pfmodel.Node, error) {
+	node, err := c.CordonNode(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, _ := c.GetContainers()
+	for _, cntr := range *containers {
+		if cntr.NodeHostname != hostname ||
+			cntr.Status == pfmodel.StatusScheduleDeletion ||
+			cntr.Status == pfmodel.StatusDeleted {
+			continue
+		}
+		_, err := c.RelocateContainer(cntr.Hostname, ext.RelocateOptions{
+			Reason: fmt.Sprintf("evacuating %s", hostname),
+		})
+		if err != nil {
+			return node, err
+		}
+	}
+	return node, nil
+}
+
+func (c *Client) GetContainers() (*pfmodel.ContainerList, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	containers := append(pfmodel.ContainerList{}, cl.containers...)
+	return &containers, nil
+}
+
+func (c *Client) GetContainer(hostname string) (*pfmodel.Container, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	i := cl.containers.FindByHostname(hostname)
+	if i < 0 {
+		return nil, notFound("container", hostname)
+	}
+	cntr := cl.containers[i]
+	return &cntr, nil
+}
+
+// GetContainerEvents returns the status transitions of a container, oldest
+// first.
+func (c *Client) GetContainerEvents(hostname string) (*pfmodel.ContainerEventList, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	events, ok := cl.events[hostname]
+	if !ok && cl.containers.FindByHostname(hostname) < 0 {
+		return nil, notFound("container", hostname)
+	}
+	events = append(pfmodel.ContainerEventList{}, events...)
+	return &events, nil
+}
+
+func (c *Client) CreateContainer(cntr pfmodel.Container) (*pfmodel.Container, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, ok := c.s.lookup(c.cluster)
+	if !ok {
+		return nil, notFound("cluster", c.cluster)
+	}
+	if cl.containers.FindByHostname(cntr.Hostname) >= 0 {
+		return nil, &ext.APIError{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    fmt.Sprintf("container %s already exists", cntr.Hostname),
+		}
+	}
+	cntr.Status = pfmodel.StatusPending
+	cntr.NodeHostname = ""
+	cl.containers = append(cl.containers, cntr)
+	c.s.record(cl, c.Actor, "", "", cntr)
+	return &cntr, nil
+}
+
+func (c *Client) DeleteContainer(hostname string) (*pfmodel.Container, error) {
+	return c.transition(hostname, c.Actor, "", func(cntr *pfmodel.Container) {
+		cntr.Status = pfmodel.StatusScheduleDeletion
+	})
+}
+
+func (c *Client) RescheduleContainer(hostname string) (*pfmodel.Container, error) {
+	return c.transition(hostname, c.Actor, "", func(cntr *pfmodel.Container) {
+		cntr.Status = pfmodel.StatusPending
+		cntr.NodeHostname = ""
+	})
+}
+
+func (c *Client) RelocateContainer(hostname string, opts ext.RelocateOptions) (*pfmodel.Container, error) {
+	return c.transition(hostname, c.Actor, opts.Reason, func(cntr *pfmodel.Container) {
+		cntr.Status = pfmodel.StatusScheduleRelocation
+	})
+}
+
+func (c *Client) updateNode(hostname string, update func(*pfmodel.Node)) (*pfmodel.Node, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	i := cl.nodes.FindByHostname(hostname)
+	if i < 0 {
+		return nil, notFound("node", hostname)
+	}
+	update(&cl.nodes[i])
+	node := cl.nodes[i]
+	return &node, nil
+}
+
+func (c *Client) transition(hostname, actor, message string, update func(*pfmodel.Container)) (*pfmodel.Container, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	cl, _ := c.s.lookup(c.cluster)
+	i := cl.containers.FindByHostname(hostname)
+	if i < 0 {
+		return nil, notFound("container", hostname)
+	}
+	previous := cl.containers[i].Status
+	update(&cl.containers[i])
+	cntr := cl.containers[i]
+	c.s.record(cl, actor, message, previous, cntr)
+	return &cntr, nil
+}
+
+// lookup returns the named cluster. A cluster that does not exist reads as
+// an empty one, which is not stored so reads never create clusters.
+func (s *store) lookup(name string) (*cluster, bool) {
+	cl, ok := s.clusters[name]
+	if !ok {
+		return &cluster{}, false
+	}
+	return cl, true
+}
+
+// create returns the named cluster, creating it when it does not exist.
+func (s *store) create(name string) *cluster {
+	cl, ok := s.clusters[name]
+	if !ok {
+		cl = &cluster{
+			info:   pfmodel.Cluster{Name: name, CreatedAt: s.now()},
+			events: make(map[string]pfmodel.ContainerEventList),
+		}
+		s.clusters[name] = cl
+		s.order = append(s.order, name)
+	}
+	return cl
+}
+
+func (s *store) record(cl *cluster, actor, message, previous string, cntr pfmodel.Container) {
+	cl.events[cntr.Hostname] = append(cl.events[cntr.Hostname], pfmodel.ContainerEvent{
+		Hostname:       cntr.Hostname,
+		Status:         cntr.Status,
+		PreviousStatus: previous,
+		NodeHostname:   cntr.NodeHostname,
+		Actor:          actor,
+		Message:        message,
+		CreatedAt:      s.now(),
+	})
+}
+
+func notFound(kind, name string) error {
+	return &ext.APIError{
+		StatusCode: http.StatusNotFound,
+		Message:    fmt.Sprintf("%s %s not found", kind, name),
+	}
+}
@@ -0,0 +1,134 @@
+package pftest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pathfinder-cm/pathfinder-go-client/ext"
+	"github.com/pathfinder-cm/pathfinder-go-client/pfmodel"
+)
+
+func TestContainerEvents(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	client := NewClient("default")
+	client.AddNode(pfmodel.Node{Hostname: "node-01", Schedulable: true})
+	client.SetClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})
+
+	client.CreateContainer(pfmodel.Container{Hostname: "test-01"})
+	client.AddContainer(pfmodel.Container{Hostname: "test-01", NodeHostname: "node-01", Status: pfmodel.StatusBootstrapStarted})
+	client.SetContainerStatus("test-01", pfmodel.StatusBootstrapError, "node-01", "chef run failed")
+
+	tables := []struct {
+		status         string
+		previousStatus string
+		nodeHostname   string
+		actor          string
+		createdAt      time.Time
+	}{
+		{pfmodel.StatusPending, "", "", DefaultActor, start.Add(time.Minute)},
+		{pfmodel.StatusBootstrapError, pfmodel.StatusBootstrapStarted, "node-01", "node-01", start.Add(2 * time.Minute)},
+	}
+
+	var c ext.Client = client
+	events, err := c.GetContainerEvents("test-01")
+	if err != nil {
+		t.Fatalf("Error when getting container events: %s", err.Error())
+	}
+	if len(*events) != len(tables) {
+		t.Fatalf("Incorrect number of events, got: %d, want: %d.", len(*events), len(tables))
+	}
+	for i, table := range tables {
+		e := (*events)[i]
+		if e.Status != table.status || e.PreviousStatus != table.previousStatus {
+			t.Errorf("Incorrect event transition, got: %s -> %s, want: %s -> %s.",
+				e.PreviousStatus, e.Status, table.previousStatus, table.status)
+		}
+		if e.NodeHostname != table.nodeHostname {
+			t.Errorf("Incorrect event node hostname, got: %s, want: %s.", e.NodeHostname, table.nodeHostname)
+		}
+		if e.Actor != table.actor {
+			t.Errorf("Incorrect event actor, got: %s, want: %s.", e.Actor, table.actor)
+		}
+		if !e.CreatedAt.Equal(table.createdAt) {
+			t.Errorf("Incorrect event CreatedAt, got: %s, want: %s.", e.CreatedAt, table.createdAt)
+		}
+	}
+	if (*events)[1].Message != "chef run failed" {
+		t.Errorf("Incorrect event message, got: %q", (*events)[1].Message)
+	}
+}
+
+func TestEvacuateNode(t *testing.T) {
+	client := NewClient("default")
+	client.AddNode(pfmodel.Node{Hostname: "node-01", Schedulable: true})
+	client.AddContainer(pfmodel.Container{Hostname: "test-01", NodeHostname: "node-01", Status: pfmodel.StatusBootstrapped})
+	client.AddContainer(pfmodel.Container{Hostname: "test-02", NodeHostname: "node-01", Status: pfmodel.StatusScheduleDeletion})
+
+	node, err := client.EvacuateNode("node-01")
+	if err != nil {
+		t.Fatalf("Error when evacuating node: %s", err.Error())
+	}
+	if !node.Cordoned || node.Schedulable {
+		t.Errorf("Node should be cordoned, got: %+v", node)
+	}
+
+	containers, _ := client.GetContainers()
+	want := []string{pfmodel.StatusScheduleRelocation, pfmodel.StatusScheduleDeletion}
+	for i, status := range want {
+		if (*containers)[i].Status != status {
+			t.Errorf("Incorrect status of %s, got: %s, want: %s.", (*containers)[i].Hostname, (*containers)[i].Status, status)
+		}
+	}
+
+	events, _ := client.GetContainerEvents("test-01")
+	if len(*events) != 1 || (*events)[0].Message != "evacuating node-01" {
+		t.Errorf("Relocation event not recorded, got: %+v", *events)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	client := NewClient("default")
+
+	_, err := client.GetContainer("test-01")
+	if apiErr, ok := err.(*ext.APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a not found *ext.APIError, got: %#v", err)
+	}
+
+	// Clusters do not share state
+	client.AddContainer(pfmodel.Container{Hostname: "test-01"})
+	if _, err := client.WithCluster("other").GetContainerEvents("test-01"); err == nil {
+		t.Errorf("Container should not be visible from another cluster")
+	}
+}
+
+func TestReadsDoNotCreateClusters(t *testing.T) {
+	client := NewClient("default")
+
+	staging := client.WithCluster("staging")
+	containers, err := staging.GetContainers()
+	if err != nil || len(*containers) != 0 {
+		t.Errorf("Unknown cluster should read as empty, got: %v %v", containers, err)
+	}
+	staging.GetNodes()
+	staging.GetContainerEvents("test-01")
+	staging.CordonNode("node-01")
+	if _, err := staging.CreateContainer(pfmodel.Container{Hostname: "test-01"}); err == nil {
+		t.Errorf("Creating a container in an unknown cluster should fail")
+	}
+
+	clusters, _ := client.ListClusters()
+	if len(*clusters) != 1 || (*clusters)[0].Name != "default" {
+		t.Errorf("Reads should not create clusters, got: %v", *clusters)
+	}
+	if _, err := client.CreateCluster(ext.CreateClusterRequest{Name: "staging", Password: "secret"}); err != nil {
+		t.Errorf("Error when creating a cluster previously read from: %s", err.Error())
+	}
+	if _, err := staging.CreateContainer(pfmodel.Container{Hostname: "test-01"}); err != nil {
+		t.Errorf("Error when creating a container in a created cluster: %s", err.Error())
+	}
+}
@@ -0,0 +1,340 @@
+// Package pftls builds TLS configurations for talking to a Pathfinder server
+// behind a private CA, optionally authenticating with a client certificate
+// and pinning the server's certificate.
+package pftls
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Config struct {
+	// CAFile is a PEM bundle of CAs trusted to sign the server certificate,
+	// on top of the system pool.
+	CAFile string
+	// CertFile and KeyFile hold the PEM client certificate and key presented
+	// to the server for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// PinnedFingerprints are hex SHA-256 fingerprints, colons allowed, of
+	// certificates of which at least one must appear in the server's chain.
+	PinnedFingerprints []string
+	// ReloadInterval is how often the client certificate files, and the
+	// CAFile when ServerName is set, are checked for rotation. Zero loads
+	// them once. The CA bundle needs ServerName because the server's name
+	// is then checked by pftls rather than crypto/tls, which does not tell
+	// it the address being dialed.
+	ReloadInterval time.Duration
+	ServerName     string
+}
+
+// Load builds a *tls.Config from cfg, reading every file up front so
+// misconfigurations are reported immediately.
+func Load(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+
+	var verifiers []func([][]byte) error
+	if cfg.CAFile != "" {
+		r, err := newCAReloader(cfg.CAFile, cfg.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ReloadInterval > 0 && cfg.ServerName != "" {
+			// crypto/tls only verifies against a fixed pool, verify the
+			// chain here so a rotated CA is trusted without a restart
+			tlsConfig.InsecureSkipVerify = true
+			verifiers = append(verifiers, func(rawCerts [][]byte) error {
+				return r.verify(rawCerts, cfg.ServerName)
+			})
+		} else {
+			tlsConfig.RootCAs = r.pool
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		r, err := newCertReloader(cfg.CertFile, cfg.KeyFile, cfg.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = r.GetClientCertificate
+	}
+
+	if len(cfg.PinnedFingerprints) > 0 {
+		pins := make(map[string]bool, len(cfg.PinnedFingerprints))
+		for _, fp := range cfg.PinnedFingerprints {
+			pins[normalizeFingerprint(fp)] = true
+		}
+		verifiers = append(verifiers, func(rawCerts [][]byte) error {
+			for _, raw := range rawCerts {
+				if pins[Fingerprint(raw)] {
+					return nil
+				}
+			}
+			return errors.New("server certificate does not match any pinned fingerprint")
+		})
+	}
+
+	if len(verifiers) > 0 {
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			for _, verify := range verifiers {
+				if err := verify(rawCerts); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of a DER certificate.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+}
+
+// ErrUnsupportedTransport is returned by Apply for HTTP clients whose
+// transport is not an *http.Transport, as their TLS settings cannot be set.
+var ErrUnsupportedTransport = errors.New("pftls: TLS configuration can only be applied to an *http.Transport")
+
+// Apply returns a copy of httpClient whose transport uses tlsConfig. The
+// original client and its transport are left untouched. Only clients using
+// the default transport or an *http.Transport can be configured, others
+// yield ErrUnsupportedTransport rather than silently talking without the
+// requested TLS settings.
+func Apply(httpClient *http.Client, tlsConfig *tls.Config) (*http.Client, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	c := *httpClient
+
+	var t *http.Transport
+	switch rt := c.Transport.(type) {
+	case nil:
+		t = copyTransport(http.DefaultTransport.(*http.Transport))
+	case *http.Transport:
+		t = copyTransport(rt)
+	default:
+		log.Errorf("Unable to apply TLS configuration to transport of type %T", rt)
+		return nil, ErrUnsupportedTransport
+	}
+	t.TLSClientConfig = tlsConfig
+	c.Transport = t
+
+	return &c, nil
+}
+
+// copyTransport copies the settings of t into a new transport with its own
+// connection pool. TLSNextProto is left out as its upgrade functions belong
+// to the connection pool of t.
+func copyTransport(t *http.Transport) *http.Transport {
+	return &http.Transport{
+		Proxy:                  t.Proxy,
+		DialContext:            t.DialContext,
+		Dial:                   t.Dial,
+		DialTLS:                t.DialTLS,
+		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
+		DisableKeepAlives:      t.DisableKeepAlives,
+		DisableCompression:     t.DisableCompression,
+		MaxIdleConns:           t.MaxIdleConns,
+		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:        t.MaxConnsPerHost,
+		IdleConnTimeout:        t.IdleConnTimeout,
+		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		ProxyConnectHeader:     t.ProxyConnectHeader,
+		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+}
+
+// certReloader serves the client certificate, reloading it when its files
+// change so rotated certificates are picked up without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	return latestModTime(r.certFile, r.keyFile)
+}
+
+func latestModTime(names ...string) (time.Time, error) {
+	var latest time.Time
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.checkedAt) >= r.interval {
+		r.checkedAt = time.Now()
+		modTime, err := r.latestModTime()
+		if err == nil && modTime.After(r.modTime) {
+			err = r.load()
+		}
+		if err != nil {
+			// Keep presenting the previous certificate until the rotation
+			// completes, a half-written pair fails to load.
+			log.Warnf("Unable to reload client certificate: %s", err.Error())
+		}
+	}
+
+	return r.cert, nil
+}
+
+// caReloader holds the pool of CAs trusted to sign the server certificate,
+// reloading the bundle when it changes.
+type caReloader struct {
+	file     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	pool      *x509.CertPool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCAReloader(file string, interval time.Duration) (*caReloader, error) {
+	r := &caReloader{
+		file:     file,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *caReloader) load() error {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	b, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return fmt.Errorf("no certificate found in %s", r.file)
+	}
+	modTime, err := latestModTime(r.file)
+	if err != nil {
+		return err
+	}
+
+	r.pool = pool
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+	return nil
+}
+
+func (r *caReloader) currentPool() *x509.CertPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.checkedAt) >= r.interval {
+		r.checkedAt = time.Now()
+		modTime, err := latestModTime(r.file)
+		if err == nil && modTime.After(r.modTime) {
+			err = r.load()
+		}
+		if err != nil {
+			// Keep trusting the previous bundle, a half-written one fails
+			// to load.
+			log.Warnf("Unable to reload CA bundle: %s", err.Error())
+		}
+	}
+
+	return r.pool
+}
+
+// verify checks that rawCerts chain up to a trusted CA and that the leaf is
+// valid for serverName, as crypto/tls would.
+func (r *caReloader) verify(rawCerts [][]byte, serverName string) error {
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	if len(certs) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         r.currentPool(),
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
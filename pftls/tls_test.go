@@ -0,0 +1,279 @@
+package pftls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error when generating key: %s", err.Error())
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Error when creating certificate: %s", err.Error())
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	keyDer, _ := x509.MarshalECPrivateKey(c.key)
+	err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600)
+	if err == nil {
+		err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	}
+	if err != nil {
+		t.Fatalf("Error when writing certificate: %s", err.Error())
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// startMutualTLSServer returns a server presenting a certificate signed by
+// ca and requiring client certificates signed by ca. It answers with the
+// common name of the client certificate.
+func startMutualTLSServer(t *testing.T, ca *testCert) *httptest.Server {
+	server := newTestCert(t, "pathfinder", ca, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	ts.StartTLS()
+	return ts
+}
+
+func get(t *testing.T, c *http.Client, url string) (string, error) {
+	res, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	b, _ := ioutil.ReadAll(res.Body)
+	return string(b), nil
+}
+
+func TestLoadMutualTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pftls")
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil, true)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "agent-01", ca, false).write(t, dir, "agent")
+
+	ts := startMutualTLSServer(t, ca)
+	defer ts.Close()
+
+	tlsConfig, err := Load(Config{
+		CAFile:         caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("Error when loading TLS config: %s", err.Error())
+	}
+	httpClient, err := Apply(&http.Client{}, tlsConfig)
+	if err != nil {
+		t.Fatalf("Error when applying TLS config: %s", err.Error())
+	}
+
+	name, err := get(t, httpClient, ts.URL)
+	if err != nil || name != "agent-01" {
+		t.Fatalf("Incorrect client certificate presented, got: %q (%v), want: %q.", name, err, "agent-01")
+	}
+
+	// Rotate the client certificate on disk
+	time.Sleep(10 * time.Millisecond)
+	newTestCert(t, "agent-02", ca, false).write(t, dir, "agent")
+	httpClient.Transport.(*http.Transport).CloseIdleConnections()
+
+	name, err = get(t, httpClient, ts.URL)
+	if err != nil || name != "agent-02" {
+		t.Errorf("Rotated client certificate not picked up, got: %q (%v), want: %q.", name, err, "agent-02")
+	}
+}
+
+func startTLSServer(t *testing.T, ca *testCert) *httptest.Server {
+	server := newTestCert(t, "pathfinder", ca, false)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("ok"))
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{server.tlsCertificate()}}
+	ts.StartTLS()
+	return ts
+}
+
+func TestLoadReloadsCA(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "pftls")
+	defer os.RemoveAll(dir)
+
+	oldCA := newTestCert(t, "old-ca", nil, true)
+	newCA := newTestCert(t, "new-ca", nil, true)
+	caFile, _ := oldCA.write(t, dir, "ca")
+
+	tlsConfig, err := Load(Config{
+		CAFile:         caFile,
+		ServerName:     "127.0.0.1",
+		ReloadInterval: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("Error when loading TLS config: %s", err.Error())
+	}
+	httpClient, err := Apply(&http.Client{}, tlsConfig)
+	if err != nil {
+		t.Fatalf("Error when applying TLS config: %s", err.Error())
+	}
+
+	oldServer := startTLSServer(t, oldCA)
+	defer oldServer.Close()
+	newServer := startTLSServer(t, newCA)
+	defer newServer.Close()
+
+	if _, err := get(t, httpClient, oldServer.URL); err != nil {
+		t.Fatalf("Server signed by the trusted CA should be accepted, got: %s", err.Error())
+	}
+	if _, err := get(t, httpClient, newServer.URL); err == nil {
+		t.Fatalf("Server signed by an untrusted CA should be rejected")
+	}
+
+	misnamed, _ := Load(Config{CAFile: caFile, ServerName: "pathfinder.invalid", ReloadInterval: time.Second})
+	misnamedClient, _ := Apply(&http.Client{}, misnamed)
+	if _, err := get(t, misnamedClient, oldServer.URL); err == nil {
+		t.Fatalf("Server certificate should be checked against ServerName")
+	}
+
+	// Rotate the CA on disk
+	time.Sleep(10 * time.Millisecond)
+	newCA.write(t, dir, "ca")
+	httpClient.Transport.(*http.Transport).CloseIdleConnections()
+
+	if _, err := get(t, httpClient, newServer.URL); err != nil {
+		t.Errorf("Rotated CA not picked up, got: %s", err.Error())
+	}
+	if _, err := get(t, httpClient, oldServer.URL); err == nil {
+		t.Errorf("Server signed by the replaced CA should be rejected")
+	}
+}
+
+func TestLoadPinnedFingerprints(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	tables := []struct {
+		fingerprint string
+		ok          bool
+	}{
+		{Fingerprint(ts.Certificate().Raw), true},
+		{"00:11:22", false},
+	}
+
+	for _, table := range tables {
+		tlsConfig, err := Load(Config{PinnedFingerprints: []string{table.fingerprint}})
+		if err != nil {
+			t.Fatalf("Error when loading TLS config: %s", err.Error())
+		}
+		tlsConfig.RootCAs = pool
+
+		httpClient, err := Apply(nil, tlsConfig)
+		if err != nil {
+			t.Fatalf("Error when applying TLS config: %s", err.Error())
+		}
+		_, err = get(t, httpClient, ts.URL)
+		if (err == nil) != table.ok {
+			t.Errorf("Incorrect pinning result for %s, got error: %v", table.fingerprint, err)
+		}
+	}
+}
+
+func TestApplyLeavesOriginalUntouched(t *testing.T) {
+	original := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 7}}
+	configured, err := Apply(original, &tls.Config{ServerName: "pathfinder"})
+	if err != nil {
+		t.Fatalf("Error when applying TLS config: %s", err.Error())
+	}
+
+	if original.Transport.(*http.Transport).TLSClientConfig != nil {
+		t.Errorf("Original transport should not be modified")
+	}
+	copied := configured.Transport.(*http.Transport)
+	if copied.TLSClientConfig.ServerName != "pathfinder" {
+		t.Errorf("TLS config not applied to the copy")
+	}
+	if copied.MaxIdleConnsPerHost != 7 {
+		t.Errorf("Transport settings not copied, got MaxIdleConnsPerHost: %d, want: %d.", copied.MaxIdleConnsPerHost, 7)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestApplyUnsupportedTransport(t *testing.T) {
+	original := &http.Client{Transport: roundTripFunc(http.DefaultTransport.RoundTrip)}
+	configured, err := Apply(original, &tls.Config{ServerName: "pathfinder"})
+	if err != ErrUnsupportedTransport || configured != nil {
+		t.Errorf("Incorrect result for a custom RoundTripper, got: %v %v, want: %v.", configured, err, ErrUnsupportedTransport)
+	}
+}
@@ -0,0 +1,201 @@
+// Package pftrace creates a span per client operation and propagates it to
+// Pathfinder server with W3C Trace Context headers, so agent-side work can be
+// correlated with server-side logs.
+package pftrace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+
+	FlagSampled byte = 0x01
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span propagated to the server.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// TraceParent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses a traceparent header value, e.g. to continue a
+// trace started by an incoming request.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+
+	var flags [1]byte
+	_, err1 := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, err2 := hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	_, err3 := hex.Decode(flags[:], []byte(parts[3]))
+	if err1 != nil || err2 != nil || err3 != nil || !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	sc.Flags = flags[0]
+
+	return sc, nil
+}
+
+// Inject sets the traceparent and tracestate headers of sc on h.
+func Inject(sc SpanContext, h http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceParentHeader, sc.TraceParent())
+	if sc.TraceState != "" {
+		h.Set(TraceStateHeader, sc.TraceState)
+	}
+}
+
+// Span is a single traced operation.
+type Span interface {
+	Context() SpanContext
+	SetAttribute(key string, value interface{})
+	// End finishes the span, marking it failed when err is not nil.
+	End(err error)
+}
+
+// Tracer starts spans. Implement it to bridge the clients to another
+// tracing library.
+type Tracer interface {
+	Start(ctx context.Context, operation string) (context.Context, Span)
+}
+
+type spanKey struct{}
+
+// ContextWithSpanContext makes sc the parent of spans started from the
+// returned context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// SpanData is what an Exporter receives for each finished span.
+type SpanData struct {
+	Operation  string
+	Context    SpanContext
+	Parent     SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Err        error
+}
+
+type Exporter interface {
+	Export(SpanData)
+}
+
+type ExporterFunc func(SpanData)
+
+func (f ExporterFunc) Export(data SpanData) { f(data) }
+
+// NewTracer returns a Tracer handing every finished span to exporter. Spans
+// continue the trace found in the context, or start a new sampled one.
+func NewTracer(exporter Exporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+type tracer struct {
+	exporter Exporter
+}
+
+func (t *tracer) Start(ctx context.Context, operation string) (context.Context, Span) {
+	s := &span{
+		tracer: t,
+		data: SpanData{
+			Operation:  operation,
+			Start:      time.Now(),
+			Attributes: make(map[string]interface{}),
+		},
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		s.data.Context = parent
+		s.data.Parent = parent.SpanID
+	} else {
+		rand.Read(s.data.Context.TraceID[:])
+		s.data.Context.Flags = FlagSampled
+	}
+	rand.Read(s.data.Context.SpanID[:])
+
+	return ContextWithSpanContext(ctx, s.data.Context), s
+}
+
+type span struct {
+	tracer *tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+func (s *span) Context() SpanContext {
+	return s.data.Context
+}
+
+func (s *span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+func (s *span) End(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.data.Err = err
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.Export(data)
+	}
+}
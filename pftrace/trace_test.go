@@ -0,0 +1,76 @@
+package pftrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestTraceParent(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceParent(value)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || sc.Flags != FlagSampled {
+		t.Errorf("Incorrect span context parsed, got: %+v", sc)
+	}
+	if sc.TraceParent() != value {
+		t.Errorf("Incorrect traceparent, got: %s, want: %s.", sc.TraceParent(), value)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceParent(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var exported []SpanData
+	tracer := NewTracer(ExporterFunc(func(data SpanData) {
+		exported = append(exported, data)
+	}))
+
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent.TraceState = "vendor=value"
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	_, span := tracer.Start(ctx, "Register")
+	span.SetAttribute("http.status_code", 200)
+	span.End(errors.New("failed"))
+	span.End(nil)
+
+	if len(exported) != 1 {
+		t.Fatalf("Span should be exported once, got: %d", len(exported))
+	}
+	data := exported[0]
+	if data.Operation != "Register" || data.Context.TraceID != parent.TraceID || data.Parent != parent.SpanID {
+		t.Errorf("Span should continue the parent trace, got: %+v", data)
+	}
+	if data.Context.SpanID == parent.SpanID || !data.Context.SpanID.IsValid() {
+		t.Errorf("Span should get its own id, got: %s", data.Context.SpanID)
+	}
+	if data.Err == nil || data.Attributes["http.status_code"] != 200 {
+		t.Errorf("Incorrect span outcome, got: %+v", data)
+	}
+
+	h := http.Header{}
+	Inject(span.Context(), h)
+	if h.Get(TraceParentHeader) != span.Context().TraceParent() || h.Get(TraceStateHeader) != "vendor=value" {
+		t.Errorf("Incorrect headers injected, got: %v", h)
+	}
+
+	_, root := tracer.Start(context.Background(), "Heartbeat")
+	if !root.Context().IsValid() || root.Context().Flags != FlagSampled {
+		t.Errorf("Span without parent should start a sampled trace, got: %+v", root.Context())
+	}
+}